@@ -0,0 +1,292 @@
+package bunker
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/git-lfs/wildmatch"
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"gorm.io/gorm"
+)
+
+var (
+	errUserBlocked = errors.New("user is blocked")
+	errNoGrant     = errors.New("no grant found")
+	errGrantDenied = errors.New("denied by grant")
+)
+
+// grantEvaluationOrder describes how grants are evaluated, returned along with grants so admins can reason about them
+var grantEvaluationOrder = []string{
+	"blocked users are denied",
+	"grants of the user and of groups the user belongs to are considered",
+	"grants not in effect by not_before and expires_at are ignored",
+	"a grant matches if server_user and server_id patterns match, and server labels satisfy server_selector",
+	"any matching deny grant denies access, regardless of allow grants",
+	"otherwise any matching allow grant allows access",
+	"otherwise access is denied",
+}
+
+// findUserGrants finds grants of user, including grants of groups the user belongs to
+func findUserGrants(db *dao.Query, userID string) (grants []*model.Grant, groupIDs []string, err error) {
+	if err = db.GroupMember.Where(db.GroupMember.UserID.Eq(userID)).Pluck(db.GroupMember.GroupID, &groupIDs); err != nil {
+		return
+	}
+
+	q := db.Grant.Where(db.Grant.UserID.Eq(userID))
+	if len(groupIDs) > 0 {
+		q = q.Or(db.Grant.GroupID.In(groupIDs...))
+	}
+	grants, err = q.Order(db.Grant.CreatedAt).Find()
+	return
+}
+
+// matchServerSelector checks server labels against the selector of grant, invalid selectors match nothing
+func matchServerSelector(selector string, labels map[string]string) bool {
+	sel, err := ParseLabelSelector(selector)
+	return err == nil && sel.Matches(labels)
+}
+
+// GrantTrace is the evaluation of a single grant against server_user@server_id
+type GrantTrace struct {
+	Grant *model.Grant `json:"grant"`
+	// in effect by not_before and expires_at
+	Active            bool   `json:"active"`
+	ServerUserMatched bool   `json:"server_user_matched"`
+	ServerIDMatched   bool   `json:"server_id_matched"`
+	SelectorMatched   bool   `json:"selector_matched"`
+	SelectorError     string `json:"selector_error,omitempty"`
+	// all of above, the effect of grant applies
+	Matched bool `json:"matched"`
+}
+
+func evaluateGrant(grant *model.Grant, serverUser string, serverID string, labels map[string]string, now time.Time) *GrantTrace {
+	gt := &GrantTrace{
+		Grant:             grant,
+		Active:            grant.IsActive(now),
+		ServerUserMatched: wildmatch.NewWildmatch(grant.ServerUser, wildmatch.Basename, wildmatch.CaseFold).Match(serverUser),
+		ServerIDMatched:   wildmatch.NewWildmatch(grant.ServerID, wildmatch.Basename, wildmatch.CaseFold).Match(serverID),
+	}
+
+	if sel, err := ParseLabelSelector(grant.ServerSelector); err != nil {
+		gt.SelectorError = err.Error()
+	} else {
+		gt.SelectorMatched = sel.Matches(labels)
+	}
+
+	gt.Matched = gt.Active && gt.ServerUserMatched && gt.ServerIDMatched && gt.SelectorMatched
+	return gt
+}
+
+// AccessTrace is the evaluation of access of user to server_user@server_id, step by step
+type AccessTrace struct {
+	UserID       string            `json:"user_id"`
+	UserBlocked  bool              `json:"user_blocked"`
+	GroupIDs     []string          `json:"group_ids"`
+	ServerUser   string            `json:"server_user"`
+	ServerID     string            `json:"server_id"`
+	ServerFound  bool              `json:"server_found"`
+	ServerLabels map[string]string `json:"server_labels"`
+	// every grant of the user and groups, in order of evaluation
+	Grants  []*GrantTrace `json:"grants"`
+	Granted bool          `json:"granted"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// EvaluateServerAccess is AuthorizeServerAccess with trace of every step, the trace is always returned
+func EvaluateServerAccess(_db *gorm.DB, user *model.User, serverUser string, serverID string) (server *model.Server, trace *AccessTrace, err error) {
+	trace = &AccessTrace{
+		UserID:      user.ID,
+		UserBlocked: user.IsBlocked,
+		ServerUser:  serverUser,
+		ServerID:    serverID,
+	}
+
+	defer func() {
+		trace.Granted = err == nil
+		if err != nil {
+			trace.Error = err.Error()
+		}
+	}()
+
+	if user.IsBlocked {
+		err = errUserBlocked
+		return
+	}
+
+	db := dao.Use(_db)
+
+	// find server
+	if server, err = db.Server.Preload(db.Server.Labels).Where(db.Server.ID.Eq(serverID)).First(); err != nil {
+		return
+	}
+
+	trace.ServerFound = true
+	trace.ServerLabels = server.LabelMap()
+
+	// find grants
+	var grants = []*model.Grant{}
+	if grants, trace.GroupIDs, err = findUserGrants(db, user.ID); err != nil {
+		return
+	}
+
+	var allowed, denied bool
+
+	now := time.Now()
+
+	// check if user is granted, deny overrides allow
+	for _, grant := range grants {
+		gt := evaluateGrant(grant, serverUser, serverID, trace.ServerLabels, now)
+		trace.Grants = append(trace.Grants, gt)
+
+		if !gt.Matched {
+			continue
+		}
+
+		if grant.Effect == model.GrantEffectDeny {
+			denied = true
+		} else {
+			allowed = true
+		}
+	}
+
+	if denied {
+		err = errGrantDenied
+		return
+	}
+
+	if !allowed {
+		err = errNoGrant
+		return
+	}
+
+	return
+}
+
+// AuthorizeServerAccess checks if the user is granted to access server_user@server_id, returns the server on success
+func AuthorizeServerAccess(_db *gorm.DB, user *model.User, serverUser string, serverID string) (server *model.Server, err error) {
+	server, _, err = EvaluateServerAccess(_db, user, serverUser, serverID)
+	return
+}
+
+// GrantedServerUsers returns server users granted to the user, keyed by server id, server users may contain wildcards,
+// server users denied entirely are omitted, wildcards partially denied are kept and checked by AuthorizeServerAccess
+func GrantedServerUsers(_db *gorm.DB, user *model.User) (items map[string][]string, err error) {
+	db := dao.Use(_db)
+
+	var grants []*model.Grant
+	if grants, _, err = findUserGrants(db, user.ID); err != nil {
+		return
+	}
+
+	var servers []*model.Server
+	if servers, err = db.Server.Preload(db.Server.Labels).Find(); err != nil {
+		return
+	}
+
+	items = grantedServerUsers(grants, servers, time.Now())
+	return
+}
+
+// grantedServerUsers evaluates grants of a single user against servers, see GrantedServerUsers
+func grantedServerUsers(grants []*model.Grant, servers []*model.Server, now time.Time) (items map[string][]string) {
+	var (
+		allowed = map[string][]string{}
+		denied  = map[string][]*wildmatch.Wildmatch{}
+	)
+
+	for _, grant := range grants {
+		if !grant.IsActive(now) {
+			continue
+		}
+
+		matcher := wildmatch.NewWildmatch(
+			grant.ServerID,
+			wildmatch.Basename,
+			wildmatch.CaseFold,
+		)
+
+		for _, server := range servers {
+			if !matcher.Match(server.ID) || !matchServerSelector(grant.ServerSelector, server.LabelMap()) {
+				continue
+			}
+			if grant.Effect == model.GrantEffectDeny {
+				denied[server.ID] = append(denied[server.ID], wildmatch.NewWildmatch(grant.ServerUser, wildmatch.Basename, wildmatch.CaseFold))
+			} else {
+				allowed[server.ID] = append(allowed[server.ID], grant.ServerUser)
+			}
+		}
+	}
+
+	items = map[string][]string{}
+
+	for serverID, serverUsers := range allowed {
+		for _, serverUser := range serverUsers {
+			if slices.ContainsFunc(denied[serverID], func(m *wildmatch.Wildmatch) bool { return m.Match(serverUser) }) {
+				continue
+			}
+			items[serverID] = append(items[serverID], serverUser)
+		}
+	}
+
+	return
+}
+
+// ServerAccess is a user able to access a server, with granted server users, which may contain wildcards
+type ServerAccess struct {
+	UserID      string   `json:"user_id"`
+	ServerUsers []string `json:"server_users"`
+}
+
+// ServerAccessList returns users granted to access the server, blocked users are excluded
+func ServerAccessList(_db *gorm.DB, server *model.Server) (list []ServerAccess, err error) {
+	db := dao.Use(_db)
+
+	var users []*model.User
+	if users, err = db.User.Where(db.User.IsBlocked.Is(false)).Order(db.User.ID).Find(); err != nil {
+		return
+	}
+
+	var grants []*model.Grant
+	if grants, err = db.Grant.Order(db.Grant.CreatedAt).Find(); err != nil {
+		return
+	}
+
+	var members []*model.GroupMember
+	if members, err = db.GroupMember.Find(); err != nil {
+		return
+	}
+
+	groups := map[string][]string{}
+	for _, member := range members {
+		groups[member.UserID] = append(groups[member.UserID], member.GroupID)
+	}
+
+	now := time.Now()
+
+	list = []ServerAccess{}
+
+	for _, user := range users {
+		// same as findUserGrants
+		var userGrants []*model.Grant
+		for _, grant := range grants {
+			if grant.UserID == user.ID || (grant.GroupID != "" && slices.Contains(groups[user.ID], grant.GroupID)) {
+				userGrants = append(userGrants, grant)
+			}
+		}
+
+		serverUsers := grantedServerUsers(userGrants, []*model.Server{server}, now)[server.ID]
+		if len(serverUsers) == 0 {
+			continue
+		}
+
+		slices.Sort(serverUsers)
+
+		list = append(list, ServerAccess{
+			UserID:      user.ID,
+			ServerUsers: slices.Compact(serverUsers),
+		})
+	}
+	return
+}
@@ -0,0 +1,293 @@
+package bunker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"gorm.io/gorm"
+)
+
+func createTestAccessDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	_db := createTestDatabase(t)
+	db := dao.Use(_db)
+
+	now := time.Now()
+
+	for _, user := range []*model.User{
+		{ID: "alice", CreatedAt: now, VisitedAt: now, Source: model.UserSourceLocal},
+		{ID: "bob", CreatedAt: now, VisitedAt: now, Source: model.UserSourceLocal},
+		{ID: "mallory", CreatedAt: now, VisitedAt: now, Source: model.UserSourceLocal, IsBlocked: true},
+	} {
+		if err := db.User.Create(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, server := range []*model.Server{
+		{ID: "prod-web", Address: "10.0.0.1:22", CreatedAt: now, Labels: []model.ServerLabel{{Key: "env", Value: "prod"}, {Key: "team", Value: "payments"}}},
+		{ID: "prod-db", Address: "10.0.0.2:22", CreatedAt: now, Labels: []model.ServerLabel{{Key: "env", Value: "prod"}, {Key: "tier", Value: "db"}}},
+		{ID: "dev-web", Address: "10.0.1.1:22", CreatedAt: now, Labels: []model.ServerLabel{{Key: "env", Value: "dev"}}},
+	} {
+		if err := db.Server.Create(server); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.Group.Create(&model.Group{ID: "ops", CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.GroupMember.Create(&model.GroupMember{GroupID: "ops", UserID: "alice", CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	return _db
+}
+
+func TestAuthorizeServerAccess(t *testing.T) {
+	var (
+		past   = time.Now().Add(-time.Hour)
+		future = time.Now().Add(time.Hour)
+	)
+
+	tests := []struct {
+		name       string
+		grants     []model.Grant
+		userID     string
+		serverUser string
+		serverID   string
+		expected   error
+	}{
+		{
+			name:       "no grant",
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-web",
+			expected:   errNoGrant,
+		},
+		{
+			name:       "exact allow",
+			grants:     []model.Grant{{UserID: "alice", ServerUser: "root", ServerID: "prod-web"}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-web",
+		},
+		{
+			name:       "grant of another user",
+			grants:     []model.Grant{{UserID: "bob", ServerUser: "root", ServerID: "prod-web"}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-web",
+			expected:   errNoGrant,
+		},
+		{
+			name:       "wildcard allow",
+			grants:     []model.Grant{{UserID: "alice", ServerUser: "*", ServerID: "prod-*"}},
+			userID:     "alice",
+			serverUser: "deploy",
+			serverID:   "prod-db",
+		},
+		{
+			name:       "wildcard case insensitive",
+			grants:     []model.Grant{{UserID: "alice", ServerUser: "ROOT", ServerID: "PROD-*"}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-web",
+		},
+		{
+			name:       "wildcard not matching server",
+			grants:     []model.Grant{{UserID: "alice", ServerUser: "*", ServerID: "prod-*"}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "dev-web",
+			expected:   errNoGrant,
+		},
+		{
+			name:       "wildcard not matching server user",
+			grants:     []model.Grant{{UserID: "alice", ServerUser: "app*", ServerID: "*"}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "dev-web",
+			expected:   errNoGrant,
+		},
+		{
+			name:       "group allow",
+			grants:     []model.Grant{{GroupID: "ops", ServerUser: "root", ServerID: "*"}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "dev-web",
+		},
+		{
+			name:       "group allow not member",
+			grants:     []model.Grant{{GroupID: "ops", ServerUser: "root", ServerID: "*"}},
+			userID:     "bob",
+			serverUser: "root",
+			serverID:   "dev-web",
+			expected:   errNoGrant,
+		},
+		{
+			name:       "selector allow",
+			grants:     []model.Grant{{UserID: "alice", ServerUser: "root", ServerID: "*", ServerSelector: "env=prod,team in (payments,billing)"}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-web",
+		},
+		{
+			name:       "selector not matching",
+			grants:     []model.Grant{{UserID: "alice", ServerUser: "root", ServerID: "*", ServerSelector: "env=prod,team in (payments,billing)"}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-db",
+			expected:   errNoGrant,
+		},
+		{
+			name:       "invalid selector matches nothing",
+			grants:     []model.Grant{{UserID: "alice", ServerUser: "root", ServerID: "*", ServerSelector: "env=prod,"}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-web",
+			expected:   errNoGrant,
+		},
+		{
+			name: "deny overrides allow",
+			grants: []model.Grant{
+				{UserID: "alice", ServerUser: "*", ServerID: "*"},
+				{UserID: "alice", ServerUser: "root", ServerID: "prod-*", Effect: model.GrantEffectDeny},
+			},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-web",
+			expected:   errGrantDenied,
+		},
+		{
+			name: "deny created before allow",
+			grants: []model.Grant{
+				{UserID: "alice", ServerUser: "root", ServerID: "prod-*", Effect: model.GrantEffectDeny},
+				{UserID: "alice", ServerUser: "root", ServerID: "prod-web"},
+			},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-web",
+			expected:   errGrantDenied,
+		},
+		{
+			name: "deny of group overrides allow of user",
+			grants: []model.Grant{
+				{UserID: "alice", ServerUser: "*", ServerID: "*"},
+				{GroupID: "ops", ServerUser: "*", ServerID: "*", ServerSelector: "tier=db", Effect: model.GrantEffectDeny},
+			},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-db",
+			expected:   errGrantDenied,
+		},
+		{
+			name: "deny not matching leaves allow",
+			grants: []model.Grant{
+				{UserID: "alice", ServerUser: "*", ServerID: "*"},
+				{UserID: "alice", ServerUser: "root", ServerID: "*", Effect: model.GrantEffectDeny},
+			},
+			userID:     "alice",
+			serverUser: "deploy",
+			serverID:   "prod-web",
+		},
+		{
+			name:       "deny only",
+			grants:     []model.Grant{{UserID: "alice", ServerUser: "root", ServerID: "*", Effect: model.GrantEffectDeny}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-web",
+			expected:   errGrantDenied,
+		},
+		{
+			name:       "allow expired",
+			grants:     []model.Grant{{UserID: "alice", ServerUser: "root", ServerID: "prod-web", ExpiresAt: &past}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-web",
+			expected:   errNoGrant,
+		},
+		{
+			name:       "allow not expired",
+			grants:     []model.Grant{{UserID: "alice", ServerUser: "root", ServerID: "prod-web", ExpiresAt: &future}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-web",
+		},
+		{
+			name:       "allow not yet effective",
+			grants:     []model.Grant{{UserID: "alice", ServerUser: "root", ServerID: "prod-web", NotBefore: &future}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-web",
+			expected:   errNoGrant,
+		},
+		{
+			name: "deny expired",
+			grants: []model.Grant{
+				{UserID: "alice", ServerUser: "root", ServerID: "prod-web"},
+				{UserID: "alice", ServerUser: "root", ServerID: "prod-web", Effect: model.GrantEffectDeny, ExpiresAt: &past},
+			},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "prod-web",
+		},
+		{
+			name:       "blocked user",
+			grants:     []model.Grant{{UserID: "mallory", ServerUser: "*", ServerID: "*"}},
+			userID:     "mallory",
+			serverUser: "root",
+			serverID:   "prod-web",
+			expected:   errUserBlocked,
+		},
+		{
+			name:       "unknown server",
+			grants:     []model.Grant{{UserID: "alice", ServerUser: "*", ServerID: "*"}},
+			userID:     "alice",
+			serverUser: "root",
+			serverID:   "missing",
+			expected:   gorm.ErrRecordNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_db := createTestAccessDatabase(t)
+			db := dao.Use(_db)
+
+			for i, grant := range tt.grants {
+				grant.ID = fmt.Sprintf("grant-%d", i)
+				grant.CreatedAt = time.Now().Add(time.Duration(i) * time.Second)
+				if grant.Effect == "" {
+					grant.Effect = model.GrantEffectAllow
+				}
+				if err := db.Grant.Create(&grant); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			user, err := db.User.Where(db.User.ID.Eq(tt.userID)).First()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			server, err := AuthorizeServerAccess(_db, user, tt.serverUser, tt.serverID)
+			if tt.expected == nil {
+				if err != nil {
+					t.Fatalf("expected granted, got %v", err)
+				}
+				if server == nil || server.ID != tt.serverID {
+					t.Fatalf("expected server %s, got %v", tt.serverID, server)
+				}
+				return
+			}
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
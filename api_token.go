@@ -0,0 +1,77 @@
+package bunker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"gorm.io/gorm"
+)
+
+const (
+	apiTokenPrefix = "bkr_"
+)
+
+// GenerateAPIToken returns a new random api token and its hash
+func GenerateAPIToken() (token string, hash string) {
+	token = apiTokenPrefix + randomHex(32)
+	hash = hashAPIToken(token)
+	return
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerToken extracts the bearer token from Authorization header
+func bearerToken(req *http.Request) (token string, ok bool) {
+	scheme, token, found := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return
+	}
+	token = strings.TrimSpace(token)
+	ok = token != ""
+	return
+}
+
+// FindAPIToken finds a valid api token, returns nil if not found or expired
+func FindAPIToken(_db *gorm.DB, token string) (apiToken *model.APIToken, err error) {
+	db := dao.Use(_db)
+
+	if apiToken, err = db.APIToken.Where(db.APIToken.ID.Eq(hashAPIToken(token))).First(); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		return
+	}
+
+	now := time.Now()
+
+	if apiToken.ExpiresAt != nil && apiToken.ExpiresAt.Before(now) {
+		apiToken = nil
+		return
+	}
+
+	if _, err = db.APIToken.Where(db.APIToken.ID.Eq(apiToken.ID)).UpdateColumnSimple(db.APIToken.UsedAt.Value(now)); err != nil {
+		return
+	}
+	apiToken.UsedAt = &now
+	return
+}
+
+// RevokeUserTokens deletes web sign in tokens and api tokens of user
+func RevokeUserTokens(_db *gorm.DB, userID string) error {
+	return dao.Use(_db).Transaction(func(tx *dao.Query) (err error) {
+		if _, err = tx.Token.Where(tx.Token.UserID.Eq(userID)).Delete(); err != nil {
+			return
+		}
+		_, err = tx.APIToken.Where(tx.APIToken.UserID.Eq(userID)).Delete()
+		return
+	})
+}
@@ -1,31 +1,52 @@
 package bunker
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/git-lfs/wildmatch"
+	"github.com/gorilla/websocket"
 	"github.com/yankeguo/bunker/model"
 	"github.com/yankeguo/bunker/model/dao"
 	"github.com/yankeguo/halt"
 	"github.com/yankeguo/rg"
 	"github.com/yankeguo/ufx"
 	"go.uber.org/fx"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/ssh"
+	"gorm.io/gen"
 	"gorm.io/gen/field"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type App struct {
-	db *gorm.DB
-
-	uiOpts uiOptions
+	db       *gorm.DB
+	dataDir  string
+	sessions *SessionRegistry
+	ssh      *SSHServer
+	auth     *AuthProviders
+	auditor  *Auditor
+	webhooks *WebhookDispatcher
+	policies *PolicyEngine
+	log      *zap.SugaredLogger
+
+	uiOpts    uiOptions
+	authOpts  authOptions
+	grantOpts grantOptions
+	oidc      *oidcClient
 }
 
 type uiOptions struct {
@@ -33,82 +54,211 @@ type uiOptions struct {
 	SSHPort int    `json:"ssh_port"`
 }
 
+type authOptions struct {
+	// admins must sign in with second factor
+	AdminRequireMFA bool `json:"admin_require_mfa"`
+	// disable sign in with local password, users sign in with ldap or single sign-on only
+	DisablePassword bool `json:"disable_password"`
+	// absolute lifetime of web sign in in seconds
+	TokenLifetime int `json:"token_lifetime" default:"604800" validate:"min=60"`
+	// web sign in expires if not used for this many seconds, 0 to disable
+	TokenIdleTimeout int `json:"token_idle_timeout" default:"86400" validate:"min=0"`
+}
+
 type AppOptions struct {
 	fx.In
 
-	DB   *gorm.DB
-	Conf ufx.Conf
+	Lifecycle fx.Lifecycle
+	DB        *gorm.DB
+	Conf      ufx.Conf
+	DataDir   DataDir
+	Sessions  *SessionRegistry
+	SSHServer *SSHServer
+	Auth      *AuthProviders
+	Auditor   *Auditor
+	Webhooks  *WebhookDispatcher
+	Policies  *PolicyEngine
+	Logger    *zap.SugaredLogger
 }
 
 func CreateApp(opts AppOptions) (app *App, err error) {
 	app = &App{
-		db: opts.DB,
+		db:       opts.DB,
+		dataDir:  opts.DataDir.String(),
+		sessions: opts.Sessions,
+		ssh:      opts.SSHServer,
+		auth:     opts.Auth,
+		auditor:  opts.Auditor,
+		webhooks: opts.Webhooks,
+		policies: opts.Policies,
+		log:      opts.Logger,
+	}
+	if err = opts.Conf.Bind(&app.uiOpts, "ui"); err != nil {
+		return
+	}
+	if err = opts.Conf.Bind(&app.authOpts, "auth"); err != nil {
+		return
+	}
+	if err = opts.Conf.Bind(&app.grantOpts, "grant"); err != nil {
+		return
+	}
+	app.oidc = &oidcClient{}
+	if err = opts.Conf.Bind(&app.oidc.opts, "oidc"); err != nil {
+		return
+	}
+
+	if opts.Lifecycle != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		opts.Lifecycle.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go app.runTokenSweeper(ctx)
+				go app.runGrantExpiry(ctx)
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				cancel()
+				return nil
+			},
+		})
 	}
-	err = opts.Conf.Bind(&app.uiOpts, "ui")
 	return
 }
 
-func (a *App) currentUser(c ufx.Context) (token *model.Token, user *model.User, err error) {
-	var cookie *http.Cookie
+// currentUser finds the user of request, signed in either with token cookie or with api token as bearer token
+func (a *App) currentUser(req *http.Request) (token *model.Token, apiToken *model.APIToken, user *model.User, err error) {
+	db := dao.Use(a.db)
 
-	if cookie, err = c.Req().Cookie("token"); err != nil {
-		if errors.Is(err, http.ErrNoCookie) {
-			err = nil
+	var userID string
+
+	if bearer, ok := bearerToken(req); ok {
+		if apiToken, err = FindAPIToken(a.db, bearer); err != nil || apiToken == nil {
+			return
+		}
+		userID = apiToken.UserID
+	} else {
+		var cookie *http.Cookie
+		if cookie, err = req.Cookie("token"); err != nil {
+			if errors.Is(err, http.ErrNoCookie) {
+				err = nil
+			}
+			return
 		}
-		return
-	}
 
-	db := dao.Use(a.db)
+		if token, err = db.Token.Where(db.Token.ID.Eq(cookie.Value)).First(); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = nil
+			}
+			return
+		}
 
-	if token, err = db.Token.Where(db.Token.ID.Eq(cookie.Value)).First(); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			err = nil
+		now := time.Now()
+
+		if a.isTokenExpired(token, now) {
+			if _, err = db.Token.Where(db.Token.ID.Eq(token.ID)).Delete(); err != nil {
+				return
+			}
+			token = nil
+			return
 		}
-		return
+
+		if err = a.visitToken(token, requestIP(req), now); err != nil {
+			return
+		}
+		userID = token.UserID
 	}
 
-	if user, err = db.User.Where(db.User.ID.Eq(token.UserID)).First(); err != nil {
+	if user, err = db.User.Where(db.User.ID.Eq(userID)).First(); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			err = nil
 		}
 		return
 	}
 
+	// blocked users are treated as not signed in
+	if user.IsBlocked {
+		token, apiToken, user = nil, nil, nil
+		return
+	}
+
 	return
 }
 
 func (a *App) routeUIOptions(c ufx.Context) {
-	c.JSON(a.uiOpts)
+	c.JSON(struct {
+		uiOptions
+		OIDC            bool `json:"oidc"`
+		LDAP            bool `json:"ldap"`
+		DisablePassword bool `json:"disable_password"`
+	}{
+		uiOptions:       a.uiOpts,
+		OIDC:            a.oidc.opts.Enabled,
+		LDAP:            a.auth.Has(model.UserSourceLDAP),
+		DisablePassword: !a.auth.Enabled(),
+	})
 }
 
 func (a *App) routeCurrentUser(c ufx.Context) {
-	token, user := rg.Must2(a.currentUser(c))
+	token, apiToken, user := rg.Must3(a.currentUser(c.Req()))
 	if user != nil {
 		user.PasswordDigest = ""
 	}
 	c.JSON(map[string]any{
-		"token": token,
-		"user":  user,
+		"token":     token,
+		"api_token": apiToken,
+		"user":      user,
 	})
 }
 
-func (a *App) requireUser(c ufx.Context) (token *model.Token, user *model.User) {
-	token, user = rg.Must2(a.currentUser(c))
+// requireSignIn requires a signed in user, api tokens of read scope are limited to readOnlyRoutes
+func (a *App) requireSignIn(c ufx.Context) (token *model.Token, apiToken *model.APIToken, user *model.User) {
+	token, apiToken, user = rg.Must3(a.currentUser(c.Req()))
 
-	if user == nil || token == nil {
+	if user == nil {
 		halt.String("Not signed in")
 		return
 	}
+
+	if apiToken != nil && apiToken.Scope == model.APITokenScopeRead && !readOnlyRoutes[c.Req().URL.Path] {
+		halt.String("Token scope insufficient")
+		return
+	}
+	return
+}
+
+// requireUser requires a signed in user, token is nil if signed in with api token
+func (a *App) requireUser(c ufx.Context) (token *model.Token, user *model.User) {
+	token, _, user = a.requireSignIn(c)
 	return
 }
 
 func (a *App) requireAdmin(c ufx.Context) (token *model.Token, user *model.User) {
-	token, user = a.requireUser(c)
+	token, apiToken, user := a.requireSignIn(c)
 
 	if !user.IsAdmin {
 		halt.String("Not admin")
 		return
 	}
+
+	if apiToken != nil {
+		if apiToken.Scope == model.APITokenScopeWrite {
+			halt.String("Token scope insufficient")
+			return
+		}
+	} else if a.authOpts.AdminRequireMFA && !token.MFA {
+		halt.String("Admin requires mfa, enable totp and sign in again")
+		return
+	}
+	return
+}
+
+// requireCookie requires a user signed in with token cookie, for routes not available to api tokens
+func (a *App) requireCookie(c ufx.Context) (token *model.Token, user *model.User) {
+	token, user = a.requireUser(c)
+
+	if token == nil {
+		halt.String("Not available with api token", halt.WithBadRequest())
+		return
+	}
 	return
 }
 
@@ -120,46 +270,165 @@ func (a *App) routeSignIn(c ufx.Context) {
 	}
 	c.Bind(&data)
 
-	db := dao.Use(a.db)
+	if !a.auth.Enabled() {
+		halt.String("password sign in is disabled", halt.WithBadRequest())
+		return
+	}
 
-	// find user
-	user := rg.Must(db.User.Where(db.User.ID.Eq(data.Username)).First())
-	if !user.CheckPassword(data.Password) {
-		halt.String("invalid password", halt.WithBadRequest())
+	user, err := a.auth.Authenticate(c.Req().Context(), a.db, data.Username, data.Password)
+	if errors.Is(err, errInvalidCredentials) {
+		a.auditFailure(c.Req(), data.Username, "user.sign_in", data.Username, err.Error())
+		halt.String(err.Error(), halt.WithBadRequest())
 		return
 	}
+	rg.Must0(err)
+
 	user.PasswordDigest = ""
 
 	// must not blocked
 	if user.IsBlocked {
+		a.auditFailure(c.Req(), user.ID, "user.sign_in", user.ID, "user is blocked")
+		halt.String("blocked", halt.WithBadRequest())
+		return
+	}
+
+	// ldap users sign in with password of the directory
+	method := "password"
+	if user.Source == model.UserSourceLDAP {
+		method = "ldap"
+	}
+
+	// second factor required, sign in is completed by routeSignInMFA
+	if user.TOTPEnabled {
+		challenge := rg.Must(a.createMFAChallenge(user.ID, data.UserAgent, method))
+
+		c.JSON(map[string]any{
+			"mfa_required": true,
+			"challenge":    challenge.ID,
+		})
+		return
+	}
+
+	a.signIn(c, user, data.UserAgent, method, false)
+}
+
+func (a *App) routeSignInMFA(c ufx.Context) {
+	var data struct {
+		Challenge string `json:"challenge" validate:"required"`
+		Code      string `json:"code" validate:"required"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	challenge, err := db.MFAChallenge.Where(db.MFAChallenge.ID.Eq(data.Challenge)).First()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		halt.String("invalid challenge", halt.WithBadRequest())
+		return
+	}
+	rg.Must0(err)
+
+	if challenge.CreatedAt.Before(time.Now().Add(-mfaChallengeTTL)) || challenge.Attempts >= mfaChallengeMaxAttempts {
+		rg.Must(db.MFAChallenge.Where(db.MFAChallenge.ID.Eq(challenge.ID)).Delete())
+		halt.String("challenge expired, sign in again", halt.WithBadRequest())
+		return
+	}
+
+	// count attempts before verifying, limits guessing
+	rg.Must(db.MFAChallenge.Where(db.MFAChallenge.ID.Eq(challenge.ID)).UpdateSimple(db.MFAChallenge.Attempts.Add(1)))
+
+	user := rg.Must(db.User.Where(db.User.ID.Eq(challenge.UserID)).First())
+
+	if user.IsBlocked {
+		a.auditFailure(c.Req(), user.ID, "user.sign_in", user.ID, "user is blocked")
 		halt.String("blocked", halt.WithBadRequest())
 		return
 	}
 
-	// delete history tokens
-	rg.Must(db.Token.Where(db.Token.UserID.Eq(user.ID), db.Token.CreatedAt.Lte(time.Now().Add(-time.Hour*24*7))).Delete())
+	if err = VerifyUserMFA(a.db, user, data.Code); err != nil {
+		a.auditFailure(c.Req(), user.ID, "user.sign_in", user.ID, err.Error())
+		halt.String(err.Error(), halt.WithBadRequest())
+		return
+	}
+
+	rg.Must(db.MFAChallenge.Where(db.MFAChallenge.ID.Eq(challenge.ID)).Delete())
+
+	user.PasswordDigest = ""
+
+	a.signIn(c, user, challenge.UserAgent, challenge.Method, true)
+}
+
+// createMFAChallenge creates a pending sign-in for user, to be completed by routeSignInMFA
+func (a *App) createMFAChallenge(userID string, userAgent string, method string) (challenge *model.MFAChallenge, err error) {
+	db := dao.Use(a.db)
+
+	// delete expired challenges
+	if _, err = db.MFAChallenge.Where(db.MFAChallenge.CreatedAt.Lte(time.Now().Add(-mfaChallengeTTL))).Delete(); err != nil {
+		return
+	}
+
+	id := make([]byte, 32)
+	rand.Read(id)
+
+	challenge = &model.MFAChallenge{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		UserAgent: userAgent,
+		Method:    method,
+		CreatedAt: time.Now(),
+	}
+
+	err = db.MFAChallenge.Create(challenge)
+	return
+}
+
+// createToken creates a token for user, returns the cookie to set
+func (a *App) createToken(userID string, userAgent string, ip string, mfa bool) (token *model.Token, cookie *http.Cookie, err error) {
+	db := dao.Use(a.db)
 
 	// create token
 	id := make([]byte, 32)
 	rand.Read(id)
 
-	token := &model.Token{
+	token = &model.Token{
 		ID:        hex.EncodeToString(id),
-		UserID:    user.ID,
-		UserAgent: data.UserAgent,
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        ip,
+		MFA:       mfa,
 		CreatedAt: time.Now(),
 		VisitedAt: time.Now(),
 	}
 
-	rg.Must0(db.Token.Create(token))
+	if err = db.Token.Create(token); err != nil {
+		return
+	}
 
-	cookie := &http.Cookie{
+	cookie = &http.Cookie{
 		Name:     "token",
 		Value:    token.ID,
-		MaxAge:   3600 * 24 * 7,
+		MaxAge:   a.authOpts.TokenLifetime,
 		Path:     "/",
 		HttpOnly: !Debug("ui"),
 	}
+	return
+}
+
+// auditSignIn records a successful sign in with method
+func (a *App) auditSignIn(req *http.Request, token *model.Token, method string) {
+	a.audit(req, token.UserID, "user.sign_in", token.UserID, nil, map[string]any{
+		"method":      method,
+		"mfa":         token.MFA,
+		"user_agent":  token.UserAgent,
+		"web_session": webSessionID(token.ID),
+	})
+}
+
+// signIn issues a token for user and sets the cookie
+func (a *App) signIn(c ufx.Context, user *model.User, userAgent string, method string, mfa bool) {
+	token, cookie := rg.Must2(a.createToken(user.ID, userAgent, requestIP(c.Req()), mfa))
+
+	a.auditSignIn(c.Req(), token, method)
 
 	c.Header().Set("Set-Cookie", cookie.String())
 
@@ -170,11 +439,13 @@ func (a *App) routeSignIn(c ufx.Context) {
 }
 
 func (a *App) routeSignOut(c ufx.Context) {
-	token, _ := a.requireUser(c)
+	token, _ := a.requireCookie(c)
 
 	db := dao.Use(a.db)
 	rg.Must(db.Token.Where(db.Token.ID.Eq(token.ID)).Delete())
 
+	a.audit(c.Req(), token.UserID, "user.sign_out", token.UserID, map[string]any{"web_session": webSessionID(token.ID)}, nil)
+
 	cookie := &http.Cookie{
 		Name:     "token",
 		Value:    "",
@@ -231,6 +502,8 @@ func (a *App) routeCreateKey(c ufx.Context) {
 
 	rg.Must0(db.Key.Create(key))
 
+	a.audit(c.Req(), user.ID, "key.create", key.ID, nil, key)
+
 	c.JSON(map[string]any{"key": key})
 }
 
@@ -244,8 +517,14 @@ func (a *App) routeDeleteKey(c ufx.Context) {
 
 	db := dao.Use(a.db)
 
+	before := firstOf(rg.Must(db.Key.Where(db.Key.ID.Eq(data.ID), db.Key.UserID.Eq(user.ID)).Find()))
+
 	rg.Must(db.Key.Where(db.Key.ID.Eq(data.ID), db.Key.UserID.Eq(user.ID)).Delete())
 
+	if before != nil {
+		a.audit(c.Req(), user.ID, "key.delete", before.ID, before, nil)
+	}
+
 	c.JSON(map[string]any{})
 }
 
@@ -254,29 +533,146 @@ func (a *App) routeListServers(c ufx.Context) {
 
 	db := dao.Use(a.db)
 
-	servers := rg.Must(db.Server.Find())
+	servers := rg.Must(db.Server.Preload(db.Server.Labels).Find())
 
 	c.JSON(map[string]any{"servers": servers})
 }
 
-func (a *App) routeCreateServer(c ufx.Context) {
+// routeServerAccess lists users able to access the server, and granted server users
+func (a *App) routeServerAccess(c ufx.Context) {
 	_, _ = a.requireAdmin(c)
 
+	var data struct {
+		ServerID string `json:"server_id" validate:"required"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	server := firstOf(rg.Must(db.Server.Preload(db.Server.Labels).Where(db.Server.ID.Eq(data.ServerID)).Find()))
+	if server == nil {
+		halt.String("server not found", halt.WithBadRequest())
+		return
+	}
+
+	c.JSON(map[string]any{"server": server, "access": rg.Must(ServerAccessList(a.db, server))})
+}
+
+func (a *App) routeUpdateServerLabels(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		ServerID string `json:"server_id" validate:"required"`
+		// replaces all labels of server
+		Labels map[string]string `json:"labels"`
+	}
+	c.Bind(&data)
+
+	for key, value := range data.Labels {
+		if !model.LabelKeyPattern.MatchString(key) {
+			halt.String("invalid label key: "+key, halt.WithBadRequest())
+			return
+		}
+		if !model.LabelValuePattern.MatchString(value) {
+			halt.String("invalid label value: "+value, halt.WithBadRequest())
+			return
+		}
+	}
+
+	db := dao.Use(a.db)
+
+	before := firstOf(rg.Must(db.Server.Preload(db.Server.Labels).Where(db.Server.ID.Eq(data.ServerID)).Find()))
+
+	if before == nil {
+		halt.String("server not found", halt.WithBadRequest())
+		return
+	}
+
+	rg.Must0(db.Transaction(func(tx *dao.Query) (err error) {
+		if _, err = tx.ServerLabel.Where(tx.ServerLabel.ServerID.Eq(data.ServerID)).Delete(); err != nil {
+			return
+		}
+		for key, value := range data.Labels {
+			if err = tx.ServerLabel.Create(&model.ServerLabel{
+				ServerID: data.ServerID,
+				Key:      key,
+				Value:    value,
+			}); err != nil {
+				return
+			}
+		}
+		return
+	}))
+
+	after := rg.Must(db.Server.Preload(db.Server.Labels).Where(db.Server.ID.Eq(data.ServerID)).First())
+
+	a.audit(c.Req(), u.ID, "server.update_labels", data.ServerID, before.LabelMap(), after.LabelMap())
+
+	c.JSON(map[string]any{"server": after})
+}
+
+func (a *App) routeCreateServer(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
 	db := dao.Use(a.db)
 
 	var data struct {
 		ID      string `json:"id" validate:"required"`
 		Address string `json:"address" validate:"required"`
+		// kept as is if omitted
+		RequireMFA *bool `json:"require_mfa"`
 	}
 
 	c.Bind(&data)
 
-	server := rg.Must(db.Server.Where(db.Server.ID.Eq(data.ID)).Assign(db.Server.Address.Value(data.Address)).FirstOrCreate())
+	before := firstOf(rg.Must(db.Server.Where(db.Server.ID.Eq(data.ID)).Find()))
+
+	assigns := []field.AssignExpr{db.Server.Address.Value(data.Address)}
+
+	if data.RequireMFA != nil {
+		assigns = append(assigns, db.Server.RequireMFA.Value(*data.RequireMFA))
+	}
+
+	server := rg.Must(db.Server.Where(db.Server.ID.Eq(data.ID)).Assign(assigns...).FirstOrCreate())
+
+	if before == nil {
+		a.audit(c.Req(), u.ID, "server.create", server.ID, nil, server)
+	} else {
+		a.audit(c.Req(), u.ID, "server.update", server.ID, before, server)
+	}
 
 	c.JSON(map[string]any{"server": server})
 }
 
 func (a *App) routeDeleteServer(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	db := dao.Use(a.db)
+
+	var data struct {
+		ID string `json:"id" validate:"required"`
+	}
+
+	c.Bind(&data)
+
+	before := firstOf(rg.Must(db.Server.Where(db.Server.ID.Eq(data.ID)).Find()))
+
+	rg.Must0(db.Transaction(func(tx *dao.Query) (err error) {
+		if _, err = tx.ServerLabel.Where(tx.ServerLabel.ServerID.Eq(data.ID)).Delete(); err != nil {
+			return
+		}
+		_, err = tx.Server.Where(tx.Server.ID.Eq(data.ID)).Delete()
+		return
+	}))
+
+	if before != nil {
+		a.audit(c.Req(), u.ID, "server.delete", before.ID, before, nil)
+	}
+
+	c.JSON(map[string]any{})
+}
+
+func (a *App) routeServerHostKey(c ufx.Context) {
 	_, _ = a.requireAdmin(c)
 
 	db := dao.Use(a.db)
@@ -287,7 +683,49 @@ func (a *App) routeDeleteServer(c ufx.Context) {
 
 	c.Bind(&data)
 
-	rg.Must(db.Server.Where(db.Server.ID.Eq(data.ID)).Delete())
+	server := rg.Must(db.Server.Where(db.Server.ID.Eq(data.ID)).First())
+
+	var fingerprint string
+
+	if server.HostKey != "" {
+		k, _, _, _ := rg.Must4(ssh.ParseAuthorizedKey([]byte(server.HostKey)))
+		fingerprint = ssh.FingerprintSHA256(k)
+	}
+
+	c.JSON(map[string]any{
+		"host_key":    server.HostKey,
+		"fingerprint": fingerprint,
+	})
+}
+
+func (a *App) routeResetServerHostKey(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	db := dao.Use(a.db)
+
+	var data struct {
+		ID      string `json:"id" validate:"required"`
+		HostKey string `json:"host_key"`
+	}
+
+	c.Bind(&data)
+
+	// pin the given host key, or clear it to be pinned again on next connection
+	var hostKey string
+
+	if data.HostKey != "" {
+		k, _, _, _ := rg.Must4(ssh.ParseAuthorizedKey([]byte(data.HostKey)))
+		hostKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(k)))
+	}
+
+	before := rg.Must(db.Server.Where(db.Server.ID.Eq(data.ID)).First())
+
+	rg.Must(db.Server.Where(db.Server.ID.Eq(data.ID)).UpdateColumnSimple(db.Server.HostKey.Value(hostKey)))
+
+	after := *before
+	after.HostKey = hostKey
+
+	a.audit(c.Req(), u.ID, "server.reset_host_key", before.ID, before, after)
 
 	c.JSON(map[string]any{})
 }
@@ -303,7 +741,7 @@ func (a *App) routeListUsers(c ufx.Context) {
 }
 
 func (a *App) routeCreateUser(c ufx.Context) {
-	_, _ = a.requireAdmin(c)
+	_, u := a.requireAdmin(c)
 
 	db := dao.Use(a.db)
 
@@ -317,14 +755,23 @@ func (a *App) routeCreateUser(c ufx.Context) {
 		ID:        data.ID,
 		CreatedAt: time.Now(),
 		VisitedAt: time.Now(),
+		Source:    model.UserSourceLocal,
 	}
 	user.SetPassword(data.Password)
 
+	before := firstOf(rg.Must(db.User.Where(db.User.ID.Eq(data.ID)).Find()))
+
 	rg.Must0(db.User.Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "id"}},
 		DoUpdates: clause.AssignmentColumns([]string{"password_digest"}),
 	}).Create(user))
 
+	if before == nil {
+		a.audit(c.Req(), u.ID, "user.create", user.ID, nil, user)
+	} else {
+		a.audit(c.Req(), u.ID, "user.update_password", user.ID, nil, nil)
+	}
+
 	c.JSON(map[string]any{"user": user})
 }
 
@@ -352,11 +799,23 @@ func (a *App) routeUpdateUser(c ufx.Context) {
 	}
 
 	if data.IsBlocked != nil {
-		assigns = append(assigns, db.User.IsBlocked.Value(*data.IsBlocked))
+		// blocked or unblocked by admin, not to be changed by sources
+		assigns = append(assigns, db.User.IsBlocked.Value(*data.IsBlocked), db.User.BlockedBy.Value(""))
 	}
 
 	if len(assigns) != 0 {
+		before := rg.Must(db.User.Where(db.User.ID.Eq(data.ID)).First())
+
 		rg.Must(db.User.Where(db.User.ID.Eq(data.ID)).UpdateColumnSimple(assigns...))
+
+		after := rg.Must(db.User.Where(db.User.ID.Eq(data.ID)).First())
+
+		a.audit(c.Req(), u.ID, "user.update", data.ID, before, after)
+	}
+
+	if data.IsBlocked != nil && *data.IsBlocked {
+		rg.Must0(RevokeUserTokens(a.db, data.ID))
+		a.sessions.TerminateUser(data.ID, "user blocked")
 	}
 
 	c.JSON(map[string]any{})
@@ -366,50 +825,188 @@ func (a *App) routeListGrants(c ufx.Context) {
 	_, _ = a.requireAdmin(c)
 
 	var data struct {
-		UserID string `json:"user_id"`
+		UserID  string `json:"user_id"`
+		GroupID string `json:"group_id"`
 	}
 
 	c.Bind(&data)
 
 	db := dao.Use(a.db)
 
-	grants := rg.Must(db.Grant.Where(db.Grant.UserID.Eq(data.UserID)).Find())
+	grants := rg.Must(db.Grant.Where(db.Grant.UserID.Eq(data.UserID), db.Grant.GroupID.Eq(data.GroupID)).Find())
 
-	c.JSON(map[string]any{"grants": grants})
+	c.JSON(map[string]any{"grants": grants, "evaluation_order": grantEvaluationOrder})
 }
 
-func (a *App) routeCreateGrant(c ufx.Context) {
+// routeListAllGrants lists grants across users and groups, filters of server_id and server_user are matched against patterns of grants
+func (a *App) routeListAllGrants(c ufx.Context) {
 	_, _ = a.requireAdmin(c)
 
-	db := dao.Use(a.db)
-
 	var data struct {
-		UserID     string `json:"user_id" validate:"required"`
-		ServerUser string `json:"server_user" validate:"required"`
-		ServerID   string `json:"server_id" validate:"required"`
+		// grants of the user, including grants of groups the user belongs to
+		UserID     string `json:"user_id"`
+		GroupID    string `json:"group_id"`
+		ServerID   string `json:"server_id"`
+		ServerUser string `json:"server_user"`
+		Effect     string `json:"effect"`
 	}
 	c.Bind(&data)
 
-	digest := sha256.Sum256([]byte(data.UserID + "::" + data.ServerUser + "@" + data.ServerID))
-	id := hex.EncodeToString(digest[:])
+	db := dao.Use(a.db)
 
-	grant := &model.Grant{
-		ID:         id,
-		UserID:     data.UserID,
-		ServerUser: data.ServerUser,
-		ServerID:   data.ServerID,
+	var grants []*model.Grant
+	if data.UserID != "" {
+		grants, _ = rg.Must2(findUserGrants(db, data.UserID))
+	} else {
+		grants = rg.Must(db.Grant.Order(db.Grant.CreatedAt).Find())
 	}
 
-	rg.Must0(db.Grant.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "id"}},
-		DoNothing: true,
-	}).Create(grant))
+	var labels map[string]string
+	if data.ServerID != "" {
+		if server := firstOf(rg.Must(db.Server.Preload(db.Server.Labels).Where(db.Server.ID.Eq(data.ServerID)).Find())); server != nil {
+			labels = server.LabelMap()
+		}
+	}
 
-	c.JSON(map[string]any{"grant": grant})
+	result := []*model.Grant{}
+
+	for _, grant := range grants {
+		if data.GroupID != "" && grant.GroupID != data.GroupID {
+			continue
+		}
+		if data.Effect != "" && grant.Effect != data.Effect {
+			continue
+		}
+		if data.ServerID != "" {
+			if !wildmatch.NewWildmatch(grant.ServerID, wildmatch.Basename, wildmatch.CaseFold).Match(data.ServerID) {
+				continue
+			}
+			// selector is only checked for existing servers
+			if labels != nil && !matchServerSelector(grant.ServerSelector, labels) {
+				continue
+			}
+		}
+		if data.ServerUser != "" && !wildmatch.NewWildmatch(grant.ServerUser, wildmatch.Basename, wildmatch.CaseFold).Match(data.ServerUser) {
+			continue
+		}
+		result = append(result, grant)
+	}
+
+	c.JSON(map[string]any{"grants": result, "evaluation_order": grantEvaluationOrder})
 }
 
-func (a *App) routeDeleteGrant(c ufx.Context) {
-	_, _ = a.requireAdmin(c)
+func (a *App) routeCreateGrant(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	db := dao.Use(a.db)
+
+	var data struct {
+		// either user_id or group_id
+		UserID     string `json:"user_id"`
+		GroupID    string `json:"group_id"`
+		ServerUser string `json:"server_user" validate:"required"`
+		ServerID   string `json:"server_id" validate:"required"`
+		// label selector of servers, e.g. "env=prod,team in (payments,billing)"
+		ServerSelector string `json:"server_selector"`
+		// "allow" (default) or "deny"
+		Effect    string     `json:"effect"`
+		NotBefore *time.Time `json:"not_before"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		// e.g. "4h", counted from not_before or now, takes precedence over expires_at, "0" for permanent
+		Duration string `json:"duration"`
+		// never expires, instead of the default duration
+		Permanent bool `json:"permanent"`
+	}
+	c.Bind(&data)
+
+	if (data.UserID == "") == (data.GroupID == "") {
+		halt.String("exactly one of user_id and group_id is required", halt.WithBadRequest())
+		return
+	}
+
+	switch data.Effect {
+	case "":
+		data.Effect = model.GrantEffectAllow
+	case model.GrantEffectAllow, model.GrantEffectDeny:
+	default:
+		halt.String("effect must be allow or deny", halt.WithBadRequest())
+		return
+	}
+
+	selector, err := ParseLabelSelector(data.ServerSelector)
+	if err != nil {
+		halt.String(err.Error(), halt.WithBadRequest())
+		return
+	}
+
+	if data.GroupID != "" && firstOf(rg.Must(db.Group.Where(db.Group.ID.Eq(data.GroupID)).Find())) == nil {
+		halt.String("group not found", halt.WithBadRequest())
+		return
+	}
+
+	start := time.Now()
+	if data.NotBefore != nil {
+		start = *data.NotBefore
+	}
+
+	if data.Duration != "" {
+		duration, err := time.ParseDuration(data.Duration)
+		if err != nil || duration < 0 {
+			halt.String("invalid duration", halt.WithBadRequest())
+			return
+		}
+		if duration == 0 {
+			data.Permanent = true
+		} else {
+			expiresAt := start.Add(duration)
+			data.ExpiresAt = &expiresAt
+		}
+	}
+
+	if data.Permanent {
+		if data.ExpiresAt != nil {
+			halt.String("permanent grants can not expire", halt.WithBadRequest())
+			return
+		}
+	} else if data.ExpiresAt == nil && a.grantOpts.DefaultDuration > 0 && data.Effect == model.GrantEffectAllow {
+		expiresAt := start.Add(time.Duration(a.grantOpts.DefaultDuration) * time.Second)
+		data.ExpiresAt = &expiresAt
+	}
+
+	if data.ExpiresAt != nil && (!data.ExpiresAt.After(start) || !data.ExpiresAt.After(time.Now())) {
+		halt.String("expires_at must be after not_before and now", halt.WithBadRequest())
+		return
+	}
+
+	grant := &model.Grant{
+		UserID:         data.UserID,
+		GroupID:        data.GroupID,
+		ServerUser:     data.ServerUser,
+		ServerID:       data.ServerID,
+		ServerSelector: selector.String(),
+		Effect:         data.Effect,
+		NotBefore:      data.NotBefore,
+		ExpiresAt:      data.ExpiresAt,
+	}
+	grant.ID = grantID(grant)
+
+	// granting again updates the time range
+	before := rg.Must(upsertGrant(db, grant))
+
+	a.auditGrant(c.Req(), u.ID, before, grant)
+
+	// deny grants take effect on live sessions immediately
+	if grant.Effect == model.GrantEffectDeny && grant.IsActive(time.Now()) {
+		for _, userID := range rg.Must(grantUserIDs(db, grant)) {
+			rg.Must0(a.revalidateSessions(userID, "denied by grant"))
+		}
+	}
+
+	c.JSON(map[string]any{"grant": grant})
+}
+
+func (a *App) routeDeleteGrant(c ufx.Context) {
+	_, u := a.requireAdmin(c)
 
 	db := dao.Use(a.db)
 
@@ -419,40 +1016,26 @@ func (a *App) routeDeleteGrant(c ufx.Context) {
 
 	c.Bind(&data)
 
+	before := firstOf(rg.Must(db.Grant.Where(db.Grant.ID.Eq(data.ID)).Find()))
+
 	rg.Must(db.Grant.Where(db.Grant.ID.Eq(data.ID)).Delete())
 
+	if before != nil {
+		a.audit(c.Req(), u.ID, "grant.delete", before.ID, before, nil)
+	}
+
 	c.JSON(map[string]any{})
 }
 
 func (a *App) routeGrantedItems(c ufx.Context) {
 	_, u := a.requireUser(c)
 
-	db := dao.Use(a.db)
-
-	grants := rg.Must(db.Grant.Where(db.Grant.UserID.Eq(u.ID)).Find())
-
-	servers := rg.Must(db.Server.Find())
-
 	type grantedItem struct {
 		ServerUser string `json:"server_user"`
 		ServerID   string `json:"server_id"`
 	}
 
-	m := map[string][]string{}
-
-	for _, grant := range grants {
-		matcher := wildmatch.NewWildmatch(
-			grant.ServerID,
-			wildmatch.Basename,
-			wildmatch.CaseFold,
-		)
-
-		for _, server := range servers {
-			if matcher.Match(server.ID) {
-				m[server.ID] = append(m[server.ID], grant.ServerUser)
-			}
-		}
-	}
+	m := rg.Must(GrantedServerUsers(a.db, u))
 
 	grantedItems := []grantedItem{}
 
@@ -467,39 +1050,1475 @@ func (a *App) routeGrantedItems(c ufx.Context) {
 		return grantedItems[i].ServerID < grantedItems[j].ServerID
 	})
 
-	c.JSON(map[string]any{"granted_items": grantedItems})
+	c.JSON(map[string]any{"granted_items": grantedItems, "evaluation_order": grantEvaluationOrder})
 }
 
-func (a *App) routeUpdatePassword(c ufx.Context) {
-	_, u := a.requireUser(c)
+// routeExplainAccess evaluates ssh access of user to target, the same way as ssh sign in, and returns every step
+func (a *App) routeExplainAccess(c ufx.Context) {
+	_, _ = a.requireAdmin(c)
 
 	var data struct {
-		OldPassword string `json:"old_password" validate:"required"`
-		NewPassword string `json:"new_password" validate:"required,min=6"`
+		UserID string `json:"user_id" validate:"required"`
+		// server_user@server_id
+		Target string `json:"target" validate:"required"`
+		// source ip for policies, optional
+		SourceIP string `json:"source_ip"`
+	}
+	c.Bind(&data)
+
+	serverUser, serverID, err := parseSSHUser(data.Target)
+	if err != nil || serverID == "" {
+		halt.String("target should be server_user@server_id", halt.WithBadRequest())
+		return
+	}
+
+	db := dao.Use(a.db)
+
+	user := firstOf(rg.Must(db.User.Where(db.User.ID.Eq(data.UserID)).Find()))
+	if user == nil {
+		halt.String("user not found", halt.WithBadRequest())
+		return
+	}
+
+	keys := rg.Must(db.Key.Where(db.Key.UserID.Eq(user.ID)).Find())
+
+	server, trace, err := EvaluateServerAccess(a.db, user, serverUser, serverID)
+
+	// policies are only evaluated if granted
+	policies := []PolicyDecision{}
+	if err == nil {
+		policies = rg.Must(a.policies.Evaluate(PolicyInput{
+			User:       user,
+			Server:     server,
+			ServerUser: serverUser,
+			SourceIP:   data.SourceIP,
+			Time:       time.Now(),
+		}))
+		err = enforcePolicies(policies)
+	}
+
+	var mfaRequired bool
+	if err == nil {
+		mfaRequired, err = sshMFARequired(user, server)
+	}
+
+	result := map[string]any{
+		"user":             user,
+		"keys":             keys,
+		"trace":            trace,
+		"policies":         policies,
+		"mfa_required":     mfaRequired,
+		"decision":         model.GrantEffectAllow,
+		"reason":           sshAuthReason(err),
+		"evaluation_order": grantEvaluationOrder,
 	}
 
+	if err != nil {
+		result["decision"] = model.GrantEffectDeny
+		result["error"] = err.Error()
+	}
+
+	c.JSON(result)
+}
+
+func (a *App) routeListGroups(c ufx.Context) {
+	_, _ = a.requireAdmin(c)
+
+	db := dao.Use(a.db)
+
+	groups := rg.Must(db.Group.Preload(db.Group.Members).Order(db.Group.ID).Find())
+
+	c.JSON(map[string]any{"groups": groups})
+}
+
+func (a *App) routeCreateGroup(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		ID          string `json:"id" validate:"required"`
+		Description string `json:"description"`
+	}
 	c.Bind(&data)
 
-	if !u.CheckPassword(data.OldPassword) {
-		halt.String("invalid old password", halt.WithBadRequest())
+	if !model.GroupIDPattern.MatchString(data.ID) {
+		halt.String("invalid group id", halt.WithBadRequest())
 		return
 	}
 
-	u.SetPassword(data.NewPassword)
+	db := dao.Use(a.db)
+
+	before := firstOf(rg.Must(db.Group.Where(db.Group.ID.Eq(data.ID)).Find()))
+
+	group := rg.Must(db.Group.Where(db.Group.ID.Eq(data.ID)).Assign(
+		db.Group.Description.Value(data.Description),
+	).FirstOrCreate())
+
+	if before == nil {
+		a.audit(c.Req(), u.ID, "group.create", group.ID, nil, group)
+	} else {
+		a.audit(c.Req(), u.ID, "group.update", group.ID, before, group)
+	}
+
+	c.JSON(map[string]any{"group": group})
+}
+
+func (a *App) routeDeleteGroup(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		ID string `json:"id" validate:"required"`
+	}
+	c.Bind(&data)
 
 	db := dao.Use(a.db)
 
-	rg.Must(db.User.Where(db.User.ID.Eq(u.ID)).UpdateColumnSimple(db.User.PasswordDigest.Value(u.PasswordDigest)))
+	before := firstOf(rg.Must(db.Group.Preload(db.Group.Members).Where(db.Group.ID.Eq(data.ID)).Find()))
+
+	if before == nil {
+		c.JSON(map[string]any{})
+		return
+	}
+
+	// grants of group are deleted along with the group
+	rg.Must0(db.Transaction(func(tx *dao.Query) (err error) {
+		if _, err = tx.Grant.Where(tx.Grant.GroupID.Eq(data.ID)).Delete(); err != nil {
+			return
+		}
+		if _, err = tx.GroupMember.Where(tx.GroupMember.GroupID.Eq(data.ID)).Delete(); err != nil {
+			return
+		}
+		_, err = tx.Group.Where(tx.Group.ID.Eq(data.ID)).Delete()
+		return
+	}))
+
+	a.audit(c.Req(), u.ID, "group.delete", before.ID, before, nil)
+
+	for _, member := range before.Members {
+		rg.Must0(a.revalidateSessions(member.UserID, "group deleted"))
+	}
 
 	c.JSON(map[string]any{})
 }
 
-func InstallAppToRouter(a *App, ur ufx.Router) {
+func (a *App) routeAddGroupMember(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		GroupID string `json:"group_id" validate:"required"`
+		UserID  string `json:"user_id" validate:"required"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	if firstOf(rg.Must(db.Group.Where(db.Group.ID.Eq(data.GroupID)).Find())) == nil {
+		halt.String("group not found", halt.WithBadRequest())
+		return
+	}
+	if firstOf(rg.Must(db.User.Where(db.User.ID.Eq(data.UserID)).Find())) == nil {
+		halt.String("user not found", halt.WithBadRequest())
+		return
+	}
+
+	member := firstOf(rg.Must(db.GroupMember.Where(db.GroupMember.GroupID.Eq(data.GroupID), db.GroupMember.UserID.Eq(data.UserID)).Find()))
+
+	if member == nil {
+		member = &model.GroupMember{
+			GroupID:   data.GroupID,
+			UserID:    data.UserID,
+			CreatedAt: time.Now(),
+		}
+
+		rg.Must0(db.GroupMember.Clauses(clause.OnConflict{DoNothing: true}).Create(member))
+
+		a.audit(c.Req(), u.ID, "group.add_member", data.GroupID, nil, member)
+	}
+
+	c.JSON(map[string]any{"member": member})
+}
+
+func (a *App) routeRemoveGroupMember(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		GroupID string `json:"group_id" validate:"required"`
+		UserID  string `json:"user_id" validate:"required"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	before := firstOf(rg.Must(db.GroupMember.Where(db.GroupMember.GroupID.Eq(data.GroupID), db.GroupMember.UserID.Eq(data.UserID)).Find()))
+
+	rg.Must(db.GroupMember.Where(db.GroupMember.GroupID.Eq(data.GroupID), db.GroupMember.UserID.Eq(data.UserID)).Delete())
+
+	if before != nil {
+		a.audit(c.Req(), u.ID, "group.remove_member", data.GroupID, before, nil)
+
+		rg.Must0(a.revalidateSessions(data.UserID, "removed from group"))
+	}
+
+	c.JSON(map[string]any{})
+}
+
+func (a *App) routeListPolicies(c ufx.Context) {
+	_, _ = a.requireAdmin(c)
+
+	db := dao.Use(a.db)
+
+	policies := rg.Must(db.Policy.Order(db.Policy.ID).Find())
+
+	c.JSON(map[string]any{"policies": policies})
+}
+
+// routeCreatePolicy creates or updates a policy, the expression denies access if evaluated to true
+func (a *App) routeCreatePolicy(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		ID          string `json:"id" validate:"required"`
+		Description string `json:"description"`
+		Expression  string `json:"expression" validate:"required"`
+		// dry_run if empty
+		Mode string `json:"mode"`
+	}
+	c.Bind(&data)
+
+	if !model.PolicyIDPattern.MatchString(data.ID) {
+		halt.String("invalid policy id", halt.WithBadRequest())
+		return
+	}
+
+	if data.Mode == "" {
+		data.Mode = model.PolicyModeDryRun
+	}
+	if data.Mode != model.PolicyModeEnforce && data.Mode != model.PolicyModeDryRun && data.Mode != model.PolicyModeDisabled {
+		halt.String("invalid policy mode", halt.WithBadRequest())
+		return
+	}
+
+	if _, err := a.policies.Compile(data.Expression); err != nil {
+		halt.String("invalid policy expression: "+err.Error(), halt.WithBadRequest())
+		return
+	}
+
+	db := dao.Use(a.db)
+
+	before := firstOf(rg.Must(db.Policy.Where(db.Policy.ID.Eq(data.ID)).Find()))
+
+	policy := rg.Must(db.Policy.Where(db.Policy.ID.Eq(data.ID)).Assign(
+		db.Policy.Description.Value(data.Description),
+		db.Policy.Expression.Value(data.Expression),
+		db.Policy.Mode.Value(data.Mode),
+	).FirstOrCreate())
+
+	if before == nil {
+		a.audit(c.Req(), u.ID, "policy.create", policy.ID, nil, policy)
+	} else {
+		a.audit(c.Req(), u.ID, "policy.update", policy.ID, before, policy)
+	}
+
+	c.JSON(map[string]any{"policy": policy})
+}
+
+func (a *App) routeDeletePolicy(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		ID string `json:"id" validate:"required"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	before := firstOf(rg.Must(db.Policy.Where(db.Policy.ID.Eq(data.ID)).Find()))
+
+	rg.Must(db.Policy.Where(db.Policy.ID.Eq(data.ID)).Delete())
+
+	if before != nil {
+		a.audit(c.Req(), u.ID, "policy.delete", before.ID, before, nil)
+	}
+
+	c.JSON(map[string]any{})
+}
+
+func (a *App) routeListAccessRequests(c ufx.Context) {
+	_, u := a.requireUser(c)
+
+	db := dao.Use(a.db)
+
+	accessRequests := rg.Must(db.AccessRequest.Where(db.AccessRequest.UserID.Eq(u.ID)).Order(db.AccessRequest.CreatedAt.Desc()).Find())
+
+	c.JSON(map[string]any{"access_requests": accessRequests})
+}
+
+func (a *App) routeCreateAccessRequest(c ufx.Context) {
+	_, u := a.requireUser(c)
+
+	var data struct {
+		ServerUser    string `json:"server_user" validate:"required"`
+		ServerID      string `json:"server_id" validate:"required"`
+		Justification string `json:"justification" validate:"required"`
+		// e.g. "4h"
+		Duration string `json:"duration" validate:"required"`
+	}
+	c.Bind(&data)
+
+	data.Justification = strings.TrimSpace(data.Justification)
+
+	if data.ServerUser == "" || data.ServerID == "" || data.Justification == "" {
+		halt.String("server_user, server_id and justification are required", halt.WithBadRequest())
+		return
+	}
+
+	if strings.ContainsAny(data.ServerUser+data.ServerID, "*?[") {
+		halt.String("wildcards are not allowed", halt.WithBadRequest())
+		return
+	}
+
+	duration, err := time.ParseDuration(data.Duration)
+	if err != nil || duration < time.Second {
+		halt.String("invalid duration", halt.WithBadRequest())
+		return
+	}
+	if duration > time.Duration(a.grantOpts.MaxRequestDuration)*time.Second {
+		halt.String("duration exceeds "+(time.Duration(a.grantOpts.MaxRequestDuration)*time.Second).String(), halt.WithBadRequest())
+		return
+	}
+
+	db := dao.Use(a.db)
+
+	if firstOf(rg.Must(db.Server.Where(db.Server.ID.Eq(data.ServerID)).Find())) == nil {
+		halt.String("server not found", halt.WithBadRequest())
+		return
+	}
+
+	if rg.Must(db.AccessRequest.Where(
+		db.AccessRequest.UserID.Eq(u.ID),
+		db.AccessRequest.ServerUser.Eq(data.ServerUser),
+		db.AccessRequest.ServerID.Eq(data.ServerID),
+		db.AccessRequest.Status.Eq(model.AccessRequestPending),
+	).Count()) > 0 {
+		halt.String("access request already pending", halt.WithBadRequest())
+		return
+	}
+
+	accessRequest := &model.AccessRequest{
+		ID:            randomHex(16),
+		UserID:        u.ID,
+		ServerUser:    data.ServerUser,
+		ServerID:      data.ServerID,
+		Justification: data.Justification,
+		Duration:      int(duration / time.Second),
+		Status:        model.AccessRequestPending,
+		CreatedAt:     time.Now(),
+	}
+
+	rg.Must0(db.AccessRequest.Create(accessRequest))
+
+	a.audit(c.Req(), u.ID, "access_request.create", accessRequest.ID, nil, accessRequest)
+
+	c.JSON(map[string]any{"access_request": accessRequest})
+}
+
+func (a *App) routeCancelAccessRequest(c ufx.Context) {
+	_, u := a.requireUser(c)
+
+	var data struct {
+		ID string `json:"id" validate:"required"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	before := rg.Must(db.AccessRequest.Where(db.AccessRequest.ID.Eq(data.ID), db.AccessRequest.UserID.Eq(u.ID)).First())
+
+	now := time.Now()
+
+	if rg.Must(db.AccessRequest.Where(
+		db.AccessRequest.ID.Eq(data.ID),
+		db.AccessRequest.Status.Eq(model.AccessRequestPending),
+	).UpdateColumnSimple(
+		db.AccessRequest.Status.Value(model.AccessRequestCanceled),
+		db.AccessRequest.ReviewedAt.Value(now),
+	)).RowsAffected == 0 {
+		halt.String("access request is not pending", halt.WithBadRequest())
+		return
+	}
+
+	after := rg.Must(db.AccessRequest.Where(db.AccessRequest.ID.Eq(data.ID)).First())
+
+	a.audit(c.Req(), u.ID, "access_request.cancel", data.ID, before, after)
+
+	c.JSON(map[string]any{"access_request": after})
+}
+
+func (a *App) routeAccessRequestQueue(c ufx.Context) {
+	_, _ = a.requireAdmin(c)
+
+	var data struct {
+		// defaults to pending
+		Status string `json:"status"`
+		UserID string `json:"user_id"`
+	}
+	c.Bind(&data)
+
+	if data.Status == "" {
+		data.Status = model.AccessRequestPending
+	}
+
+	db := dao.Use(a.db)
+
+	q := db.AccessRequest.Where(db.AccessRequest.Status.Eq(data.Status))
+	if data.UserID != "" {
+		q = q.Where(db.AccessRequest.UserID.Eq(data.UserID))
+	}
+
+	accessRequests := rg.Must(q.Order(db.AccessRequest.CreatedAt).Find())
+
+	c.JSON(map[string]any{"access_requests": accessRequests})
+}
+
+func (a *App) routeReviewAccessRequest(c ufx.Context, approve bool) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		ID      string `json:"id" validate:"required"`
+		Comment string `json:"comment"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	before := rg.Must(db.AccessRequest.Where(db.AccessRequest.ID.Eq(data.ID)).First())
+
+	if before.Status != model.AccessRequestPending {
+		halt.String("access request is not pending", halt.WithBadRequest())
+		return
+	}
+
+	if approve && before.UserID == u.ID {
+		halt.String("cannot approve own access request", halt.WithStatusCode(http.StatusForbidden))
+		return
+	}
+
+	var (
+		now         = time.Now()
+		action      = "access_request.deny"
+		status      = model.AccessRequestDenied
+		grant       *model.Grant
+		grantBefore *model.Grant
+		grantDirty  bool
+	)
+
+	if approve {
+		action = "access_request.approve"
+		status = model.AccessRequestApproved
+
+		expiresAt := now.Add(time.Duration(before.Duration) * time.Second)
+
+		grant = &model.Grant{
+			UserID:     before.UserID,
+			ServerUser: before.ServerUser,
+			ServerID:   before.ServerID,
+			Effect:     model.GrantEffectAllow,
+			ExpiresAt:  &expiresAt,
+		}
+		grant.ID = grantID(grant)
+	}
+
+	rg.Must0(db.Transaction(func(tx *dao.Query) (err error) {
+		assigns := []field.AssignExpr{
+			tx.AccessRequest.Status.Value(status),
+			tx.AccessRequest.ReviewerID.Value(u.ID),
+			tx.AccessRequest.ReviewComment.Value(strings.TrimSpace(data.Comment)),
+			tx.AccessRequest.ReviewedAt.Value(now),
+		}
+		if grant != nil {
+			assigns = append(assigns, tx.AccessRequest.GrantID.Value(grant.ID))
+		}
+
+		var res gen.ResultInfo
+		if res, err = tx.AccessRequest.Where(
+			tx.AccessRequest.ID.Eq(data.ID),
+			tx.AccessRequest.Status.Eq(model.AccessRequestPending),
+		).UpdateColumnSimple(assigns...); err != nil {
+			return
+		}
+		if res.RowsAffected == 0 {
+			return halt.New(errors.New("access request is not pending"), halt.WithBadRequest())
+		}
+
+		if grant != nil {
+			grantBefore, grantDirty, err = extendGrant(tx, grant, now)
+		}
+		return
+	}))
+
+	after := rg.Must(db.AccessRequest.Where(db.AccessRequest.ID.Eq(data.ID)).First())
+
+	a.audit(c.Req(), u.ID, action, data.ID, before, after)
+
+	if grantDirty {
+		a.auditGrant(c.Req(), u.ID, grantBefore, grant)
+	}
+
+	c.JSON(map[string]any{"access_request": after, "grant": grant})
+}
+
+func (a *App) routeApproveAccessRequest(c ufx.Context) {
+	a.routeReviewAccessRequest(c, true)
+}
+
+func (a *App) routeDenyAccessRequest(c ufx.Context) {
+	a.routeReviewAccessRequest(c, false)
+}
+
+func (a *App) routeUpdatePassword(c ufx.Context) {
+	_, u := a.requireCookie(c)
+
+	var data struct {
+		OldPassword string `json:"old_password" validate:"required"`
+		NewPassword string `json:"new_password" validate:"required,min=6"`
+	}
+
+	c.Bind(&data)
+
+	if u.Source != model.UserSourceLocal {
+		halt.String("password is managed by "+u.Source, halt.WithBadRequest())
+		return
+	}
+
+	if !u.CheckPassword(data.OldPassword) {
+		a.auditFailure(c.Req(), u.ID, "user.update_password", u.ID, "invalid old password")
+		halt.String("invalid old password", halt.WithBadRequest())
+		return
+	}
+
+	u.SetPassword(data.NewPassword)
+
+	db := dao.Use(a.db)
+
+	rg.Must(db.User.Where(db.User.ID.Eq(u.ID)).UpdateColumnSimple(db.User.PasswordDigest.Value(u.PasswordDigest)))
+
+	a.audit(c.Req(), u.ID, "user.update_password", u.ID, nil, nil)
+
+	c.JSON(map[string]any{})
+}
+
+func (a *App) routeEnrollTOTP(c ufx.Context) {
+	_, u := a.requireCookie(c)
+
+	if u.TOTPEnabled {
+		halt.String("totp already enabled", halt.WithBadRequest())
+		return
+	}
+
+	db := dao.Use(a.db)
+
+	secret := model.GenerateTOTPSecret()
+
+	rg.Must(db.User.Where(db.User.ID.Eq(u.ID)).UpdateColumnSimple(
+		db.User.TOTPSecret.Value(secret),
+		db.User.TOTPLastStep.Value(0),
+	))
+
+	a.audit(c.Req(), u.ID, "totp.enroll", u.ID, nil, nil)
+
+	c.JSON(map[string]any{
+		"secret": secret,
+		"uri":    model.TOTPURI(totpIssuer, u.ID, secret),
+	})
+}
+
+func (a *App) routeEnableTOTP(c ufx.Context) {
+	_, u := a.requireCookie(c)
+
+	var data struct {
+		Code string `json:"code" validate:"required"`
+	}
+	c.Bind(&data)
+
+	if u.TOTPEnabled {
+		halt.String("totp already enabled", halt.WithBadRequest())
+		return
+	}
+
+	if err := VerifyUserTOTP(a.db, u, data.Code); err != nil {
+		halt.String(err.Error(), halt.WithBadRequest())
+		return
+	}
+
+	db := dao.Use(a.db)
+
+	rg.Must(db.User.Where(db.User.ID.Eq(u.ID)).UpdateColumnSimple(db.User.TOTPEnabled.Value(true)))
+
+	codes := rg.Must(GenerateRecoveryCodes(a.db, u.ID))
+
+	a.audit(c.Req(), u.ID, "totp.enable", u.ID, nil, nil)
+
+	c.JSON(map[string]any{"recovery_codes": codes})
+}
+
+func (a *App) routeRegenerateRecoveryCodes(c ufx.Context) {
+	_, u := a.requireCookie(c)
+
+	var data struct {
+		Code string `json:"code" validate:"required"`
+	}
+	c.Bind(&data)
+
+	if !u.TOTPEnabled {
+		halt.String("totp not enabled", halt.WithBadRequest())
+		return
+	}
+
+	if err := VerifyUserTOTP(a.db, u, data.Code); err != nil {
+		halt.String(err.Error(), halt.WithBadRequest())
+		return
+	}
+
+	codes := rg.Must(GenerateRecoveryCodes(a.db, u.ID))
+
+	a.audit(c.Req(), u.ID, "totp.recovery_codes", u.ID, nil, nil)
+
+	c.JSON(map[string]any{"recovery_codes": codes})
+}
+
+func (a *App) routeDisableTOTP(c ufx.Context) {
+	_, u := a.requireCookie(c)
+
+	var data struct {
+		Code string `json:"code" validate:"required"`
+	}
+	c.Bind(&data)
+
+	if !u.TOTPEnabled {
+		halt.String("totp not enabled", halt.WithBadRequest())
+		return
+	}
+
+	if err := VerifyUserMFA(a.db, u, data.Code); err != nil {
+		a.auditFailure(c.Req(), u.ID, "totp.disable", u.ID, err.Error())
+		halt.String(err.Error(), halt.WithBadRequest())
+		return
+	}
+
+	rg.Must0(ResetUserMFA(a.db, u.ID))
+
+	a.audit(c.Req(), u.ID, "totp.disable", u.ID, nil, nil)
+
+	c.JSON(map[string]any{})
+}
+
+func (a *App) routeResetUserMFA(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		ID string `json:"id" validate:"required"`
+	}
+	c.Bind(&data)
+
+	rg.Must0(ResetUserMFA(a.db, data.ID))
+
+	a.audit(c.Req(), u.ID, "user.reset_mfa", data.ID, nil, nil)
+
+	c.JSON(map[string]any{})
+}
+
+func (a *App) routeListAPITokens(c ufx.Context) {
+	_, u := a.requireUser(c)
+
+	db := dao.Use(a.db)
+
+	apiTokens := rg.Must(db.APIToken.Where(db.APIToken.UserID.Eq(u.ID)).Order(db.APIToken.CreatedAt.Desc()).Find())
+
+	c.JSON(map[string]any{"api_tokens": apiTokens})
+}
+
+func (a *App) routeCreateAPIToken(c ufx.Context) {
+	token, u := a.requireCookie(c)
+
+	var data struct {
+		Name      string     `json:"name" validate:"required"`
+		Scope     string     `json:"scope"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	c.Bind(&data)
+
+	if data.Name == "" {
+		halt.String("name is required", halt.WithBadRequest())
+		return
+	}
+
+	switch data.Scope {
+	case model.APITokenScopeRead, model.APITokenScopeWrite, model.APITokenScopeAdmin:
+	default:
+		halt.String("scope must be read, write or admin", halt.WithBadRequest())
+		return
+	}
+
+	if data.Scope == model.APITokenScopeAdmin {
+		if !u.IsAdmin {
+			halt.String("Not admin")
+			return
+		}
+		if a.authOpts.AdminRequireMFA && !token.MFA {
+			halt.String("Admin requires mfa, enable totp and sign in again")
+			return
+		}
+	}
+
+	if data.ExpiresAt != nil && data.ExpiresAt.Before(time.Now()) {
+		halt.String("expires_at is in the past", halt.WithBadRequest())
+		return
+	}
+
+	db := dao.Use(a.db)
+
+	secret, hash := GenerateAPIToken()
+
+	apiToken := &model.APIToken{
+		ID:        hash,
+		UserID:    u.ID,
+		Name:      data.Name,
+		Prefix:    secret[:len(apiTokenPrefix)+6],
+		Scope:     data.Scope,
+		CreatedAt: time.Now(),
+		ExpiresAt: data.ExpiresAt,
+	}
+
+	rg.Must0(db.APIToken.Create(apiToken))
+
+	a.audit(c.Req(), u.ID, "api_token.create", apiToken.ID, nil, apiToken)
+
+	// the token is only shown once
+	c.JSON(map[string]any{
+		"api_token": apiToken,
+		"token":     secret,
+	})
+}
+
+func (a *App) routeDeleteAPIToken(c ufx.Context) {
+	_, u := a.requireUser(c)
+
+	var data struct {
+		ID string `json:"id" validate:"required"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	before := firstOf(rg.Must(db.APIToken.Where(db.APIToken.ID.Eq(data.ID), db.APIToken.UserID.Eq(u.ID)).Find()))
+
+	rg.Must(db.APIToken.Where(db.APIToken.ID.Eq(data.ID), db.APIToken.UserID.Eq(u.ID)).Delete())
+
+	if before != nil {
+		a.audit(c.Req(), u.ID, "api_token.delete", before.ID, before, nil)
+	}
+
+	c.JSON(map[string]any{})
+}
+
+type webSession struct {
+	ID        string    `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	MFA       bool      `json:"mfa"`
+	CreatedAt time.Time `json:"created_at"`
+	VisitedAt time.Time `json:"visited_at"`
+	Current   bool      `json:"current"`
+}
+
+func (a *App) routeListWebSessions(c ufx.Context) {
+	token, u := a.requireUser(c)
+
+	db := dao.Use(a.db)
+
+	tokens := rg.Must(db.Token.Where(db.Token.UserID.Eq(u.ID)).Order(db.Token.VisitedAt.Desc()).Find())
+
+	now := time.Now()
+
+	webSessions := []webSession{}
+
+	for _, item := range tokens {
+		if a.isTokenExpired(item, now) {
+			continue
+		}
+		webSessions = append(webSessions, webSession{
+			ID:        webSessionID(item.ID),
+			UserAgent: item.UserAgent,
+			IP:        item.IP,
+			MFA:       item.MFA,
+			CreatedAt: item.CreatedAt,
+			VisitedAt: item.VisitedAt,
+			Current:   token != nil && token.ID == item.ID,
+		})
+	}
+
+	c.JSON(map[string]any{"web_sessions": webSessions})
+}
+
+func (a *App) routeRevokeWebSession(c ufx.Context) {
+	_, u := a.requireUser(c)
+
+	var data struct {
+		ID string `json:"id" validate:"required"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	tokens := rg.Must(db.Token.Where(db.Token.UserID.Eq(u.ID)).Find())
+
+	for _, item := range tokens {
+		if webSessionID(item.ID) == data.ID {
+			rg.Must(db.Token.Where(db.Token.ID.Eq(item.ID)).Delete())
+
+			a.audit(c.Req(), u.ID, "web_session.revoke", data.ID, nil, nil)
+		}
+	}
+
+	c.JSON(map[string]any{})
+}
+
+func (a *App) routeRevokeUserWebSessions(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		ID string `json:"id" validate:"required"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	res := rg.Must(db.Token.Where(db.Token.UserID.Eq(data.ID)).Delete())
+
+	a.audit(c.Req(), u.ID, "user.revoke_web_sessions", data.ID, nil, map[string]any{"revoked": res.RowsAffected})
+
+	c.JSON(map[string]any{"revoked": res.RowsAffected})
+}
+
+func (a *App) routeListSessions(c ufx.Context) {
+	_, _ = a.requireAdmin(c)
+
+	var data struct {
+		UserID   string     `json:"user_id"`
+		ServerID string     `json:"server_id"`
+		Since    *time.Time `json:"since"`
+		Until    *time.Time `json:"until"`
+		Limit    int        `json:"limit,string"`
+		Offset   int        `json:"offset,string"`
+	}
+	c.Bind(&data)
+
+	if data.Limit <= 0 || data.Limit > 1000 {
+		data.Limit = 100
+	}
+
+	db := dao.Use(a.db)
+
+	q := db.Session.Order(db.Session.StartedAt.Desc())
+
+	if data.UserID != "" {
+		q = q.Where(db.Session.UserID.Eq(data.UserID))
+	}
+	if data.ServerID != "" {
+		q = q.Where(db.Session.ServerID.Eq(data.ServerID))
+	}
+	// sessions overlapping with the given time range
+	if data.Since != nil {
+		q = q.Where(db.Session.Where(db.Session.EndedAt.IsNull()).Or(db.Session.EndedAt.Gte(*data.Since)))
+	}
+	if data.Until != nil {
+		q = q.Where(db.Session.StartedAt.Lte(*data.Until))
+	}
+
+	sessions := rg.Must(q.Limit(data.Limit).Offset(data.Offset).Find())
+
+	c.JSON(map[string]any{"sessions": sessions})
+}
+
+func (a *App) routeSessionDetail(c ufx.Context) {
+	_, _ = a.requireAdmin(c)
+
+	var data struct {
+		ID string `json:"id" validate:"required"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	session := rg.Must(db.Session.Where(db.Session.ID.Eq(data.ID)).First())
+
+	recordings := []string{}
+
+	if entries, err := os.ReadDir(filepath.Join(a.dataDir, "recordings", session.ID)); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".cast") {
+				recordings = append(recordings, entry.Name())
+			}
+		}
+	}
+
+	c.JSON(map[string]any{"session": session, "recordings": recordings})
+}
+
+func (a *App) routeSessionRecording(c ufx.Context) {
+	_, _ = a.requireAdmin(c)
+
+	var data struct {
+		ID   string `json:"id" validate:"required"`
+		Name string `json:"name" validate:"required"`
+	}
+	c.Bind(&data)
+
+	if filepath.Base(data.ID) != data.ID || filepath.Base(data.Name) != data.Name || !strings.HasSuffix(data.Name, ".cast") {
+		halt.String("invalid recording", halt.WithBadRequest())
+		return
+	}
+
+	buf := rg.Must(os.ReadFile(filepath.Join(a.dataDir, "recordings", data.ID, data.Name)))
+
+	c.Body("application/x-asciicast", buf)
+}
+
+func (a *App) routeListActiveSessions(c ufx.Context) {
+	_, _ = a.requireAdmin(c)
+
+	c.JSON(map[string]any{"sessions": a.sessions.List()})
+}
+
+func (a *App) routeTerminateSession(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		ID string `json:"id" validate:"required"`
+	}
+	c.Bind(&data)
+
+	if !a.sessions.Terminate(data.ID, "terminated by "+u.ID) {
+		halt.String("session not found", halt.WithStatusCode(http.StatusNotFound))
+		return
+	}
+
+	a.audit(c.Req(), u.ID, "session.terminate", data.ID, nil, nil)
+
+	c.JSON(map[string]any{})
+}
+
+func (a *App) routeListAuditEvents(c ufx.Context) {
+	_, _ = a.requireAdmin(c)
+
+	var data struct {
+		auditEventFilter
+		Limit  int `json:"limit,string"`
+		Offset int `json:"offset,string"`
+	}
+	c.Bind(&data)
+
+	if data.Limit <= 0 || data.Limit > 1000 {
+		data.Limit = 100
+	}
+
+	db := dao.Use(a.db)
+
+	events := rg.Must(db.AuditEvent.Where(data.conditions(db)...).Order(db.AuditEvent.ID.Desc()).Limit(data.Limit).Offset(data.Offset).Find())
+
+	c.JSON(map[string]any{"audit_events": events})
+}
+
+func (a *App) routeExportAuditEvents(c ufx.Context) {
+	_, _ = a.requireAdmin(c)
+
+	var data struct {
+		auditEventFilter
+		Format string `json:"format"`
+	}
+	c.Bind(&data)
+
+	if data.Format == "" {
+		data.Format = "jsonl"
+	}
+	if data.Format != "csv" && data.Format != "jsonl" {
+		halt.String("format must be csv or jsonl", halt.WithBadRequest())
+		return
+	}
+
+	buf := &bytes.Buffer{}
+
+	rg.Must0(exportAuditEvents(a.db, data.auditEventFilter, data.Format, buf))
+
+	contentType := "application/jsonl"
+	if data.Format == "csv" {
+		contentType = "text/csv"
+	}
+
+	c.Header().Set("Content-Disposition", "attachment; filename=audit_events."+data.Format)
+	c.Body(contentType, buf.Bytes())
+}
+
+func validateWebhook(hook *model.Webhook) {
+	if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		halt.String("url must be a http or https url", halt.WithBadRequest())
+		return
+	}
+	if strings.TrimSpace(strings.ReplaceAll(hook.Events, ",", "")) == "" {
+		halt.String("events is required", halt.WithBadRequest())
+		return
+	}
+}
+
+func (a *App) routeListWebhooks(c ufx.Context) {
+	_, _ = a.requireAdmin(c)
+
+	db := dao.Use(a.db)
+
+	webhooks := rg.Must(db.Webhook.Order(db.Webhook.CreatedAt).Find())
+
+	c.JSON(map[string]any{"webhooks": webhooks})
+}
+
+func (a *App) routeCreateWebhook(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		URL    string `json:"url"`
+		Events string `json:"events"`
+		Secret string `json:"secret"`
+	}
+	c.Bind(&data)
+
+	if data.Secret == "" {
+		data.Secret = randomHex(32)
+	}
+
+	webhook := &model.Webhook{
+		ID:        randomHex(8),
+		URL:       data.URL,
+		Events:    data.Events,
+		Secret:    data.Secret,
+		Enabled:   true,
+		CreatedAt: time.Now(),
+	}
+
+	validateWebhook(webhook)
+
+	db := dao.Use(a.db)
+
+	rg.Must0(db.Webhook.Create(webhook))
+
+	a.audit(c.Req(), u.ID, "webhook.create", webhook.ID, nil, webhook)
+
+	// the secret is only shown once
+	c.JSON(map[string]any{
+		"webhook": webhook,
+		"secret":  webhook.Secret,
+	})
+}
+
+func (a *App) routeUpdateWebhook(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		ID      string  `json:"id"`
+		URL     *string `json:"url"`
+		Events  *string `json:"events"`
+		Secret  *string `json:"secret"`
+		Enabled *bool   `json:"enabled"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	before := rg.Must(db.Webhook.Where(db.Webhook.ID.Eq(data.ID)).First())
+
+	webhook := *before
+
+	if data.URL != nil {
+		webhook.URL = *data.URL
+	}
+	if data.Events != nil {
+		webhook.Events = *data.Events
+	}
+	if data.Secret != nil && *data.Secret != "" {
+		webhook.Secret = *data.Secret
+	}
+	if data.Enabled != nil {
+		webhook.Enabled = *data.Enabled
+	}
+
+	validateWebhook(&webhook)
+
+	rg.Must(db.Webhook.Where(db.Webhook.ID.Eq(webhook.ID)).UpdateColumnSimple(
+		db.Webhook.URL.Value(webhook.URL),
+		db.Webhook.Events.Value(webhook.Events),
+		db.Webhook.Secret.Value(webhook.Secret),
+		db.Webhook.Enabled.Value(webhook.Enabled),
+	))
+
+	a.audit(c.Req(), u.ID, "webhook.update", webhook.ID, before, webhook)
+
+	c.JSON(map[string]any{"webhook": webhook})
+}
+
+func (a *App) routeDeleteWebhook(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		ID string `json:"id"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	before := firstOf(rg.Must(db.Webhook.Where(db.Webhook.ID.Eq(data.ID)).Find()))
+
+	rg.Must(db.Webhook.Where(db.Webhook.ID.Eq(data.ID)).Delete())
+
+	if before != nil {
+		a.audit(c.Req(), u.ID, "webhook.delete", before.ID, before, nil)
+	}
+
+	c.JSON(map[string]any{})
+}
+
+func (a *App) routeListWebhookDeliveries(c ufx.Context) {
+	_, _ = a.requireAdmin(c)
+
+	var data struct {
+		WebhookID string `json:"webhook_id"`
+		Status    string `json:"status"`
+		Limit     int    `json:"limit,string"`
+		Offset    int    `json:"offset,string"`
+	}
+	c.Bind(&data)
+
+	if data.Limit <= 0 || data.Limit > 1000 {
+		data.Limit = 100
+	}
+
+	db := dao.Use(a.db)
+
+	q := db.WebhookDelivery.Order(db.WebhookDelivery.ID.Desc())
+
+	if data.WebhookID != "" {
+		q = q.Where(db.WebhookDelivery.WebhookID.Eq(data.WebhookID))
+	}
+	if data.Status != "" {
+		q = q.Where(db.WebhookDelivery.Status.Eq(data.Status))
+	}
+
+	deliveries := rg.Must(q.Limit(data.Limit).Offset(data.Offset).Find())
+
+	c.JSON(map[string]any{"deliveries": deliveries})
+}
+
+func (a *App) routeRetryWebhookDelivery(c ufx.Context) {
+	_, u := a.requireAdmin(c)
+
+	var data struct {
+		ID int64 `json:"id,string"`
+	}
+	c.Bind(&data)
+
+	db := dao.Use(a.db)
+
+	res := rg.Must(db.WebhookDelivery.Where(
+		db.WebhookDelivery.ID.Eq(data.ID),
+		db.WebhookDelivery.Status.Eq(model.WebhookDeliveryFailed),
+	).UpdateColumnSimple(
+		db.WebhookDelivery.Status.Value(model.WebhookDeliveryPending),
+		db.WebhookDelivery.NextAttemptAt.Value(time.Now()),
+	))
+
+	if res.RowsAffected == 0 {
+		halt.String("failed delivery not found", halt.WithBadRequest())
+		return
+	}
+
+	a.audit(c.Req(), u.ID, "webhook.retry_delivery", strconv.FormatInt(data.ID, 10), nil, nil)
+
+	a.webhooks.Notify()
+
+	c.JSON(map[string]any{})
+}
+
+var shadowUpgrader = websocket.Upgrader{}
+
+// serveSessionShadow streams a live session to an admin over websocket,
+// terminal output is sent as binary messages and events as text messages,
+// binary messages from the admin are written as input if joined with mode=join,
+// or after a text message {"type":"join"} upgrades the read-only watcher
+func (a *App) serveSessionShadow(rw http.ResponseWriter, req *http.Request) {
+	// websocket routes are only available to token cookie
+	token, _, u, err := a.currentUser(req)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if u == nil || token == nil {
+		http.Error(rw, "Not signed in", http.StatusUnauthorized)
+		return
+	}
+	if !u.IsAdmin {
+		http.Error(rw, "Not admin", http.StatusForbidden)
+		return
+	}
+	if a.authOpts.AdminRequireMFA && !token.MFA {
+		http.Error(rw, "Admin requires mfa, enable totp and sign in again", http.StatusForbidden)
+		return
+	}
+
+	var (
+		id          = req.URL.Query().Get("id")
+		interactive = req.URL.Query().Get("mode") == "join"
+	)
+
+	ls := a.sessions.Get(id)
+	if ls == nil {
+		http.Error(rw, "session not found", http.StatusNotFound)
+		return
+	}
+
+	conn, err := shadowUpgrader.Upgrade(rw, req, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	log := a.log.With(
+		"admin", u.ID,
+		"session_id", ls.ID,
+		"user_id", ls.UserID,
+		"server_id", ls.ServerID,
+		"server_user", ls.ServerUser,
+		"interactive", interactive,
+	)
+
+	log.Info("session shadow attached")
+	defer log.Info("session shadow detached")
+
+	a.audit(req, u.ID, "session.shadow", ls.ID, nil, map[string]any{"interactive": interactive})
+
+	var (
+		startedAt = time.Now()
+		ended     bool
+	)
+
+	w := ls.Shadow.Watch(u.ID, interactive)
+	defer w.Close()
+
+	// detached by admin, or ended with the session
+	defer func() {
+		action := "session.shadow_detach"
+		if ended {
+			action = "session.shadow_end"
+		}
+		a.audit(req, u.ID, action, ls.ID, nil, map[string]any{
+			"interactive":      w.Interactive(),
+			"duration_seconds": int(time.Since(startedAt) / time.Second),
+		})
+	}()
+
+	go func() {
+		defer w.Close()
+		for {
+			kind, buf, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			if kind == websocket.BinaryMessage {
+				if err = w.Input(buf); err != nil {
+					log.With("error", err).Warn("session shadow input")
+				}
+				continue
+			}
+
+			var msg WebTerminalMessage
+			if json.Unmarshal(buf, &msg) != nil {
+				continue
+			}
+
+			if msg.Type == "join" && w.Join() {
+				log.Info("session shadow joined")
+				a.audit(req, u.ID, "session.shadow_join", ls.ID, map[string]any{"interactive": false}, map[string]any{"interactive": true})
+			}
+		}
+	}()
+
+	for evt := range w.Events() {
+		if evt.Type == "closed" {
+			ended = true
+		}
+		if evt.Type == "output" {
+			err = conn.WriteMessage(websocket.BinaryMessage, evt.Data)
+		} else {
+			err = conn.WriteJSON(evt)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+var terminalUpgrader = websocket.Upgrader{}
+
+// serveTerminal opens a shell on a granted server for the signed-in user, see PipeWebTerminal for the protocol
+func (a *App) serveTerminal(rw http.ResponseWriter, req *http.Request) {
+	// websocket routes are only available to token cookie
+	token, _, u, err := a.currentUser(req)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if u == nil || token == nil {
+		http.Error(rw, "Not signed in", http.StatusUnauthorized)
+		return
+	}
+
+	var (
+		serverUser = req.URL.Query().Get("server_user")
+		serverID   = req.URL.Query().Get("server_id")
+	)
+
+	target := serverUser + "@" + serverID
+
+	var server *model.Server
+	if server, err = AuthorizeServerAccess(a.db, u, serverUser, serverID); err == nil {
+		err = a.policies.Authorize(PolicyInput{
+			User:       u,
+			Server:     server,
+			ServerUser: serverUser,
+			SourceIP:   requestIP(req),
+			Time:       time.Now(),
+		})
+	}
+	if err != nil {
+		a.auditFailure(req, u.ID, "terminal.open", target, auditReason(err))
+		http.Error(rw, err.Error(), http.StatusForbidden)
+		return
+	}
+	if server.RequireMFA && !token.MFA {
+		a.auditFailure(req, u.ID, "terminal.open", target, "server requires mfa")
+		http.Error(rw, "server requires mfa, enable totp and sign in again", http.StatusForbidden)
+		return
+	}
+
+	a.audit(req, u.ID, "terminal.open", target, nil, nil)
+
+	width, _ := strconv.Atoi(req.URL.Query().Get("width"))
+	height, _ := strconv.Atoi(req.URL.Query().Get("height"))
+
+	if width <= 0 || height <= 0 {
+		width, height = recordingDefaultWidth, recordingDefaultHeight
+	}
+
+	conn, err := terminalUpgrader.Upgrade(rw, req, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ws := &websocketWriter{conn: conn}
+
+	id := make([]byte, 32)
+	rand.Read(id)
+
+	sessionID := hex.EncodeToString(id)
+
+	log := a.log.With(
+		"remote_addr", req.RemoteAddr,
+		"user_id", u.ID,
+		"server_user", serverUser,
+		"server_id", serverID,
+		"session_id", sessionID,
+	)
+
+	live, end := a.ssh.BeginSession(log, &model.Session{
+		ID:         sessionID,
+		UserID:     u.ID,
+		ServerID:   serverID,
+		ServerUser: serverUser,
+		RemoteAddr: req.RemoteAddr,
+	})
+	defer end()
+
+	live.AddCloser(conn)
+
+	var client *ssh.Client
+	if client, err = a.ssh.DialServer(u.ID, serverID, serverUser); err != nil {
+		log.With("error", err).Error("web terminal dial")
+		live.Stats.SetCloseReason("dial failed: " + err.Error())
+		ws.WriteJSON(WebTerminalMessage{Type: "error", Message: err.Error()})
+		return
+	}
+	defer client.Close()
+
+	live.AddCloser(client)
+
+	log.Info("web terminal established")
+
+	go func() {
+		client.Wait()
+		live.Stats.SetCloseReason("target disconnected")
+		conn.Close()
+	}()
+
+	if err = PipeWebTerminal(log, a.ssh.RecordingDir(), live, client, ws, width, height); err != nil {
+		log.With("error", err).Error("web terminal")
+		live.Stats.SetCloseReason("terminal failed: " + err.Error())
+		ws.WriteJSON(WebTerminalMessage{Type: "error", Message: err.Error()})
+		return
+	}
+
+	live.Stats.SetCloseReason("user disconnected")
+}
+
+// readOnlyRoutes are routes available to api tokens of read scope
+var readOnlyRoutes = map[string]bool{
+	"/backend/current_user":          true,
+	"/backend/granted_items":         true,
+	"/backend/keys":                  true,
+	"/backend/servers":               true,
+	"/backend/servers/host_key":      true,
+	"/backend/users":                 true,
+	"/backend/grants":                true,
+	"/backend/grants/all":            true,
+	"/backend/servers/access":        true,
+	"/backend/groups":                true,
+	"/backend/policies":              true,
+	"/backend/access/explain":        true,
+	"/backend/access_requests":       true,
+	"/backend/access_requests/queue": true,
+	"/backend/api_tokens":            true,
+	"/backend/web_sessions":          true,
+	"/backend/sessions":              true,
+	"/backend/sessions/detail":       true,
+	"/backend/sessions/recording":    true,
+	"/backend/sessions/active":       true,
+	"/backend/audit_events":          true,
+	"/backend/audit_events/export":   true,
+	"/backend/webhooks":              true,
+	"/backend/webhooks/deliveries":   true,
+}
+
+func InstallAppToRouter(a *App, ur ufx.Router) {
+	ur = metricsRouter{Router: ur}
+
 	ur.HandleFunc("/backend/ui_options", a.routeUIOptions)
 	ur.HandleFunc("/backend/sign_in", a.routeSignIn)
+	ur.HandleFunc("/backend/sign_in/mfa", a.routeSignInMFA)
+	ur.ServeMux().HandleFunc("/backend/oidc/sign_in", a.serveOIDCSignIn)
+	ur.ServeMux().HandleFunc("/backend/oidc/callback", a.serveOIDCCallback)
 	ur.HandleFunc("/backend/sign_out", a.routeSignOut)
 	ur.HandleFunc("/backend/update_password", a.routeUpdatePassword)
 	ur.HandleFunc("/backend/current_user", a.routeCurrentUser)
+	ur.HandleFunc("/backend/totp/enroll", a.routeEnrollTOTP)
+	ur.HandleFunc("/backend/totp/enable", a.routeEnableTOTP)
+	ur.HandleFunc("/backend/totp/disable", a.routeDisableTOTP)
+	ur.HandleFunc("/backend/totp/recovery_codes", a.routeRegenerateRecoveryCodes)
 	ur.HandleFunc("/backend/granted_items", a.routeGrantedItems)
 	ur.HandleFunc("/backend/keys", a.routeListKeys)
 	ur.HandleFunc("/backend/keys/create", a.routeCreateKey)
@@ -507,10 +2526,52 @@ func InstallAppToRouter(a *App, ur ufx.Router) {
 	ur.HandleFunc("/backend/servers", a.routeListServers)
 	ur.HandleFunc("/backend/servers/create", a.routeCreateServer)
 	ur.HandleFunc("/backend/servers/delete", a.routeDeleteServer)
+	ur.HandleFunc("/backend/servers/labels/update", a.routeUpdateServerLabels)
+	ur.HandleFunc("/backend/servers/access", a.routeServerAccess)
+	ur.HandleFunc("/backend/servers/host_key", a.routeServerHostKey)
+	ur.HandleFunc("/backend/servers/host_key/reset", a.routeResetServerHostKey)
 	ur.HandleFunc("/backend/users", a.routeListUsers)
 	ur.HandleFunc("/backend/users/create", a.routeCreateUser)
 	ur.HandleFunc("/backend/users/update", a.routeUpdateUser)
+	ur.HandleFunc("/backend/users/reset_mfa", a.routeResetUserMFA)
 	ur.HandleFunc("/backend/grants", a.routeListGrants)
+	ur.HandleFunc("/backend/grants/all", a.routeListAllGrants)
 	ur.HandleFunc("/backend/grants/create", a.routeCreateGrant)
 	ur.HandleFunc("/backend/grants/delete", a.routeDeleteGrant)
+	ur.HandleFunc("/backend/access/explain", a.routeExplainAccess)
+	ur.HandleFunc("/backend/groups", a.routeListGroups)
+	ur.HandleFunc("/backend/groups/create", a.routeCreateGroup)
+	ur.HandleFunc("/backend/groups/delete", a.routeDeleteGroup)
+	ur.HandleFunc("/backend/groups/members/add", a.routeAddGroupMember)
+	ur.HandleFunc("/backend/groups/members/remove", a.routeRemoveGroupMember)
+	ur.HandleFunc("/backend/policies", a.routeListPolicies)
+	ur.HandleFunc("/backend/policies/create", a.routeCreatePolicy)
+	ur.HandleFunc("/backend/policies/delete", a.routeDeletePolicy)
+	ur.HandleFunc("/backend/access_requests", a.routeListAccessRequests)
+	ur.HandleFunc("/backend/access_requests/create", a.routeCreateAccessRequest)
+	ur.HandleFunc("/backend/access_requests/cancel", a.routeCancelAccessRequest)
+	ur.HandleFunc("/backend/access_requests/queue", a.routeAccessRequestQueue)
+	ur.HandleFunc("/backend/access_requests/approve", a.routeApproveAccessRequest)
+	ur.HandleFunc("/backend/access_requests/deny", a.routeDenyAccessRequest)
+	ur.HandleFunc("/backend/api_tokens", a.routeListAPITokens)
+	ur.HandleFunc("/backend/api_tokens/create", a.routeCreateAPIToken)
+	ur.HandleFunc("/backend/api_tokens/delete", a.routeDeleteAPIToken)
+	ur.HandleFunc("/backend/web_sessions", a.routeListWebSessions)
+	ur.HandleFunc("/backend/web_sessions/revoke", a.routeRevokeWebSession)
+	ur.HandleFunc("/backend/users/revoke_web_sessions", a.routeRevokeUserWebSessions)
+	ur.HandleFunc("/backend/sessions", a.routeListSessions)
+	ur.HandleFunc("/backend/sessions/detail", a.routeSessionDetail)
+	ur.HandleFunc("/backend/sessions/recording", a.routeSessionRecording)
+	ur.HandleFunc("/backend/sessions/active", a.routeListActiveSessions)
+	ur.HandleFunc("/backend/sessions/terminate", a.routeTerminateSession)
+	ur.HandleFunc("/backend/audit_events", a.routeListAuditEvents)
+	ur.HandleFunc("/backend/audit_events/export", a.routeExportAuditEvents)
+	ur.HandleFunc("/backend/webhooks", a.routeListWebhooks)
+	ur.HandleFunc("/backend/webhooks/create", a.routeCreateWebhook)
+	ur.HandleFunc("/backend/webhooks/update", a.routeUpdateWebhook)
+	ur.HandleFunc("/backend/webhooks/delete", a.routeDeleteWebhook)
+	ur.HandleFunc("/backend/webhooks/deliveries", a.routeListWebhookDeliveries)
+	ur.HandleFunc("/backend/webhooks/deliveries/retry", a.routeRetryWebhookDelivery)
+	ur.ServeMux().HandleFunc("/backend/sessions/shadow", a.serveSessionShadow)
+	ur.ServeMux().HandleFunc("/backend/terminal", a.serveTerminal)
 }
@@ -0,0 +1,232 @@
+package bunker
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"gorm.io/gen"
+	"gorm.io/gorm"
+)
+
+// Auditor records audit events of administrative and security actions
+type Auditor struct {
+	db       *gorm.DB
+	webhooks *WebhookDispatcher
+	log      *zap.SugaredLogger
+}
+
+type AuditorOptions struct {
+	fx.In
+
+	DB       *gorm.DB
+	Webhooks *WebhookDispatcher
+	Logger   *zap.SugaredLogger
+}
+
+func CreateAuditor(opts AuditorOptions) *Auditor {
+	return &Auditor{
+		db:       opts.DB,
+		webhooks: opts.Webhooks,
+		log:      opts.Logger,
+	}
+}
+
+// Record saves the event and queues webhook deliveries, failures are logged and never fail the audited action
+func (au *Auditor) Record(event *model.AuditEvent) {
+	if event.Result == "" {
+		event.Result = model.AuditResultSuccess
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	db := dao.Use(au.db)
+
+	if err := db.Transaction(func(tx *dao.Query) (err error) {
+		if err = tx.AuditEvent.Create(event); err != nil {
+			return
+		}
+		return au.webhooks.Enqueue(tx, event)
+	}); err != nil {
+		au.log.With(
+			"actor", event.Actor,
+			"action", event.Action,
+			"target", event.Target,
+			"error", err,
+		).Error("audit")
+		return
+	}
+
+	au.webhooks.Notify()
+}
+
+// auditState encodes a record as state of audit event, nil records are encoded as empty
+func auditState(v any) json.RawMessage {
+	buf, err := json.Marshal(v)
+	if err != nil || string(buf) == "null" {
+		return nil
+	}
+	return buf
+}
+
+// auditReason converts error to a readable reason of audit event
+func auditReason(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "not found"
+	}
+	return err.Error()
+}
+
+// addrIP returns the ip of a network address in host:port form
+func addrIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// audit records a successful action performed from request
+func (a *App) audit(req *http.Request, actor string, action string, target string, before any, after any) {
+	a.auditor.Record(&model.AuditEvent{
+		Actor:    actor,
+		Action:   action,
+		Target:   target,
+		Before:   auditState(before),
+		After:    auditState(after),
+		SourceIP: requestIP(req),
+	})
+}
+
+// auditFailure records a failed action performed from request
+func (a *App) auditFailure(req *http.Request, actor string, action string, target string, reason string) {
+	a.auditor.Record(&model.AuditEvent{
+		Actor:    actor,
+		Action:   action,
+		Target:   target,
+		Result:   model.AuditResultFailure,
+		Reason:   reason,
+		SourceIP: requestIP(req),
+	})
+}
+
+// firstOf returns the first item or nil, used to capture state that may not exist
+func firstOf[T any](items []*T) *T {
+	if len(items) == 0 {
+		return nil
+	}
+	return items[0]
+}
+
+// auditEventFilter filters audit events, shared by listing and exporting
+type auditEventFilter struct {
+	Actor string `json:"actor"`
+	// matches the action and actions under it, e.g. ssh.auth matches ssh.auth.publickey
+	Action string     `json:"action"`
+	Target string     `json:"target"`
+	Result string     `json:"result"`
+	Since  *time.Time `json:"since"`
+	Until  *time.Time `json:"until"`
+}
+
+func (f auditEventFilter) conditions(db *dao.Query) (conds []gen.Condition) {
+	if f.Actor != "" {
+		conds = append(conds, db.AuditEvent.Actor.Eq(f.Actor))
+	}
+	if f.Action != "" {
+		// '/' is next to '.', the range covers all actions prefixed with "action."
+		conds = append(conds, db.AuditEvent.Where(db.AuditEvent.Action.Eq(f.Action)).Or(
+			db.AuditEvent.Action.Gt(f.Action+"."),
+			db.AuditEvent.Action.Lt(f.Action+"/"),
+		))
+	}
+	if f.Target != "" {
+		conds = append(conds, db.AuditEvent.Target.Eq(f.Target))
+	}
+	if f.Result != "" {
+		conds = append(conds, db.AuditEvent.Result.Eq(f.Result))
+	}
+	if f.Since != nil {
+		conds = append(conds, db.AuditEvent.CreatedAt.Gte(*f.Since))
+	}
+	if f.Until != nil {
+		conds = append(conds, db.AuditEvent.CreatedAt.Lte(*f.Until))
+	}
+	return
+}
+
+var auditEventCSVHeader = []string{
+	"id", "created_at", "actor", "action", "target", "result", "reason", "source_ip", "before", "after",
+}
+
+func auditEventCSVRecord(event *model.AuditEvent) []string {
+	return []string{
+		strconv.FormatInt(event.ID, 10),
+		event.CreatedAt.Format(time.RFC3339),
+		event.Actor,
+		event.Action,
+		event.Target,
+		event.Result,
+		event.Reason,
+		event.SourceIP,
+		string(event.Before),
+		string(event.After),
+	}
+}
+
+// exportAuditEvents writes audit events matching filter to w, format is csv or jsonl
+func exportAuditEvents(_db *gorm.DB, f auditEventFilter, format string, w io.Writer) (err error) {
+	db := dao.Use(_db)
+
+	var (
+		cw    *csv.Writer
+		write func(event *model.AuditEvent) error
+	)
+
+	if format == "csv" {
+		cw = csv.NewWriter(w)
+		if err = cw.Write(auditEventCSVHeader); err != nil {
+			return
+		}
+		write = func(event *model.AuditEvent) error {
+			return cw.Write(auditEventCSVRecord(event))
+		}
+	} else {
+		enc := json.NewEncoder(w)
+		write = func(event *model.AuditEvent) error {
+			return enc.Encode(event)
+		}
+	}
+
+	var events []*model.AuditEvent
+
+	if err = db.AuditEvent.Where(f.conditions(db)...).FindInBatches(&events, 1000, func(tx gen.Dao, batch int) error {
+		for _, event := range events {
+			if err := write(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return
+	}
+
+	if cw != nil {
+		cw.Flush()
+		err = cw.Error()
+	}
+	return
+}
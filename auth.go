@@ -0,0 +1,162 @@
+package bunker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"github.com/yankeguo/ufx"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	errInvalidCredentials = errors.New("invalid username or password")
+)
+
+// AuthProvider authenticates users with username and password
+type AuthProvider interface {
+	// Source returns the model.User source of users managed by this provider
+	Source() string
+
+	// Authenticate checks the credentials, the user is created or updated on success
+	Authenticate(ctx context.Context, username string, password string) (*model.User, error)
+}
+
+// AuthProviders dispatches password sign in to the provider managing the user
+type AuthProviders struct {
+	providers []AuthProvider
+}
+
+type AuthProvidersOptions struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Conf      ufx.Conf
+	DB        *gorm.DB
+	Sessions  *SessionRegistry
+	Auditor   *Auditor
+	Logger    *zap.SugaredLogger
+}
+
+func CreateAuthProviders(opts AuthProvidersOptions) (ap *AuthProviders, err error) {
+	var authOpts authOptions
+	if err = opts.Conf.Bind(&authOpts, "auth"); err != nil {
+		return
+	}
+
+	ap = &AuthProviders{}
+
+	if !authOpts.DisablePassword {
+		ap.providers = append(ap.providers, &localAuthProvider{db: opts.DB})
+	}
+
+	var ldapOpts ldapOptions
+	if err = opts.Conf.Bind(&ldapOpts, "ldap"); err != nil {
+		return
+	}
+
+	if ldapOpts.Enabled {
+		lp := &ldapAuthProvider{
+			opts:     ldapOpts,
+			db:       opts.DB,
+			sessions: opts.Sessions,
+			auditor:  opts.Auditor,
+			log:      opts.Logger,
+		}
+		ap.providers = append(ap.providers, lp)
+
+		if ldapOpts.SyncInterval > 0 && opts.Lifecycle != nil {
+			ctx, cancel := context.WithCancel(context.Background())
+			opts.Lifecycle.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					go lp.RunSync(ctx)
+					return nil
+				},
+				OnStop: func(context.Context) error {
+					cancel()
+					return nil
+				},
+			})
+		}
+	}
+
+	return
+}
+
+// Enabled returns true if any provider is enabled
+func (ap *AuthProviders) Enabled() bool {
+	return len(ap.providers) > 0
+}
+
+// Has returns true if the provider of source is enabled
+func (ap *AuthProviders) Has(source string) bool {
+	for _, p := range ap.providers {
+		if p.Source() == source {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate authenticates existing users with the provider of their source,
+// unknown users are tried with every provider in order
+func (ap *AuthProviders) Authenticate(ctx context.Context, db *gorm.DB, username string, password string) (user *model.User, err error) {
+	if password == "" {
+		err = errInvalidCredentials
+		return
+	}
+
+	q := dao.Use(db)
+
+	var existing *model.User
+	if existing, err = q.User.Where(q.User.ID.Eq(username)).First(); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+		err = nil
+	}
+
+	for _, p := range ap.providers {
+		if existing != nil && existing.Source != p.Source() {
+			continue
+		}
+		if user, err = p.Authenticate(ctx, username, password); err == nil {
+			return
+		}
+		if existing != nil || !errors.Is(err, errInvalidCredentials) {
+			return
+		}
+	}
+
+	err = errInvalidCredentials
+	return
+}
+
+// localAuthProvider authenticates users with bcrypt passwords stored in database
+type localAuthProvider struct {
+	db *gorm.DB
+}
+
+func (p *localAuthProvider) Source() string {
+	return model.UserSourceLocal
+}
+
+func (p *localAuthProvider) Authenticate(ctx context.Context, username string, password string) (user *model.User, err error) {
+	db := dao.Use(p.db)
+
+	if user, err = db.User.Where(db.User.ID.Eq(username), db.User.Source.Eq(model.UserSourceLocal)).First(); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errInvalidCredentials
+		}
+		return
+	}
+
+	if !user.CheckPassword(password) {
+		user, err = nil, errInvalidCredentials
+		return
+	}
+	return
+}
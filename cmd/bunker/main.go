@@ -48,6 +48,11 @@ func main() {
 			bunker.CreateDatabase,
 			bunker.CreateSSHServer,
 			bunker.CreateSigners,
+			bunker.CreateSessionRegistry,
+			bunker.CreateWebhookDispatcher,
+			bunker.CreateAuditor,
+			bunker.CreateAuthProviders,
+			bunker.CreatePolicyEngine,
 			bunker.CreateApp,
 		),
 
@@ -56,6 +61,7 @@ func main() {
 			bunker.InstallStaticToRouter,
 			bunker.InstallSignersToRouter,
 			bunker.InstallAppToRouter,
+			bunker.InstallMetricsToRouter,
 		),
 
 		fx.Invoke(func(s *bunker.SSHServer) {}),
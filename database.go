@@ -53,6 +53,7 @@ func InitializeUsers(
 					CreatedAt: time.Now(),
 					VisitedAt: time.Now(),
 					IsAdmin:   iu.IsAdmin,
+					Source:    model.UserSourceLocal,
 				}
 				user.SetPassword(iu.Password)
 
@@ -63,6 +64,11 @@ func InitializeUsers(
 				return
 			}
 		} else if iu.UpdateExisting {
+			if user.Source != model.UserSourceLocal {
+				log.With("username", iu.Username, "source", user.Source).Warn("user not updated, not a local user")
+				continue
+			}
+
 			log.With("username", iu.Username).Info("user updated")
 
 			user.SetPassword(iu.Password)
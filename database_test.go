@@ -0,0 +1,22 @@
+package bunker
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func createTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := CreateDatabase(DataDir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
@@ -0,0 +1,191 @@
+package bunker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"gorm.io/gen"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	grantExpiryInterval = time.Minute
+)
+
+type grantOptions struct {
+	// allow grants expire after this many seconds unless expiry is given or permanent is requested, 0 for permanent grants
+	DefaultDuration int `json:"default_duration" default:"28800" validate:"min=0"`
+	// maximum duration in seconds users can request access for
+	MaxRequestDuration int `json:"max_request_duration" default:"86400" validate:"gt=0"`
+}
+
+func grantID(grant *model.Grant) string {
+	subject := grant.UserID
+	if grant.GroupID != "" {
+		// user ids never contain ':'
+		subject = "group:" + grant.GroupID
+	}
+	target := grant.ServerUser + "@" + grant.ServerID
+	if grant.ServerSelector != "" {
+		target += "?" + grant.ServerSelector
+	}
+	if grant.Effect == model.GrantEffectDeny {
+		target = "!" + target
+	}
+	digest := sha256.Sum256([]byte(subject + "::" + target))
+	return hex.EncodeToString(digest[:])
+}
+
+// grantUserIDs returns ids of users the grant applies to
+func grantUserIDs(db *dao.Query, grant *model.Grant) (userIDs []string, err error) {
+	if grant.GroupID == "" {
+		userIDs = []string{grant.UserID}
+		return
+	}
+	err = db.GroupMember.Where(db.GroupMember.GroupID.Eq(grant.GroupID)).Pluck(db.GroupMember.UserID, &userIDs)
+	return
+}
+
+// upsertGrant creates the grant, or updates the time range of existing one
+func upsertGrant(tx *dao.Query, grant *model.Grant) (before *model.Grant, err error) {
+	var grants []*model.Grant
+	if grants, err = tx.Grant.Where(tx.Grant.ID.Eq(grant.ID)).Find(); err != nil {
+		return
+	}
+	before = firstOf(grants)
+
+	if err = tx.Grant.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"not_before", "expires_at"}),
+	}).Create(grant); err != nil {
+		return
+	}
+
+	if before != nil {
+		grant.CreatedAt = before.CreatedAt
+	}
+	return
+}
+
+func (a *App) auditGrant(req *http.Request, actor string, before *model.Grant, grant *model.Grant) {
+	if before == nil {
+		a.audit(req, actor, "grant.create", grant.ID, nil, grant)
+	} else {
+		a.audit(req, actor, "grant.update", grant.ID, before, grant)
+	}
+}
+
+// extendGrant is like upsertGrant, but never shortens an existing grant already in effect
+func extendGrant(tx *dao.Query, grant *model.Grant, now time.Time) (before *model.Grant, changed bool, err error) {
+	var grants []*model.Grant
+	if grants, err = tx.Grant.Where(tx.Grant.ID.Eq(grant.ID)).Find(); err != nil {
+		return
+	}
+
+	if existing := firstOf(grants); existing != nil && existing.IsActive(now) &&
+		(existing.ExpiresAt == nil || (grant.ExpiresAt != nil && !existing.ExpiresAt.Before(*grant.ExpiresAt))) {
+		*grant = *existing
+		return
+	}
+
+	if before, err = upsertGrant(tx, grant); err != nil {
+		return
+	}
+	changed = true
+	return
+}
+
+// expireGrants deletes expired grants and terminates live sessions no longer granted
+func (a *App) expireGrants() (err error) {
+	db := dao.Use(a.db)
+
+	now := time.Now()
+
+	var grants []*model.Grant
+	if grants, err = db.Grant.Where(db.Grant.ExpiresAt.Lte(now)).Find(); err != nil {
+		return
+	}
+
+	users := map[string]bool{}
+
+	for _, grant := range grants {
+		// grant may be extended meanwhile
+		var res gen.ResultInfo
+		if res, err = db.Grant.Where(db.Grant.ID.Eq(grant.ID), db.Grant.ExpiresAt.Lte(now)).Delete(); err != nil {
+			return
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+
+		a.log.With(
+			"grant_id", grant.ID,
+			"user_id", grant.UserID,
+			"server_user", grant.ServerUser,
+			"server_id", grant.ServerID,
+		).Info("grant expired")
+
+		a.auditor.Record(&model.AuditEvent{
+			Action: "grant.expire",
+			Target: grant.ID,
+			Before: auditState(grant),
+		})
+
+		var userIDs []string
+		if userIDs, err = grantUserIDs(db, grant); err != nil {
+			return
+		}
+		for _, userID := range userIDs {
+			users[userID] = true
+		}
+	}
+
+	for userID := range users {
+		if err = a.revalidateSessions(userID, "grant expired"); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// revalidateSessions terminates live sessions of user no longer granted
+func (a *App) revalidateSessions(userID string, reason string) (err error) {
+	db := dao.Use(a.db)
+
+	var user *model.User
+	if user, err = db.User.Where(db.User.ID.Eq(userID)).First(); err != nil {
+		return
+	}
+
+	for _, ls := range a.sessions.List() {
+		if ls.UserID != userID {
+			continue
+		}
+		if _, err := AuthorizeServerAccess(a.db, user, ls.ServerUser, ls.ServerID); err != nil {
+			a.sessions.Terminate(ls.ID, reason)
+		}
+	}
+	return
+}
+
+// runGrantExpiry expires grants periodically until ctx is done
+func (a *App) runGrantExpiry(ctx context.Context) {
+	ticker := time.NewTicker(grantExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.expireGrants(); err != nil {
+				a.log.With("error", err).Error("grant expiry")
+			}
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package bunker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"go.uber.org/zap"
+	"gorm.io/gen"
+	"gorm.io/gorm"
+)
+
+type ldapOptions struct {
+	Enabled bool `json:"enabled"`
+	// ldap:// or ldaps://
+	URL                string `json:"url" validate:"required_if=Enabled true"`
+	StartTLS           bool   `json:"start_tls"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	CAFile             string `json:"ca_file"`
+	// service account for searching, anonymous if empty
+	BindDN       string `json:"bind_dn"`
+	BindPassword string `json:"bind_password"`
+	BaseDN       string `json:"base_dn" validate:"required_if=Enabled true"`
+	// %s is replaced with the escaped username
+	UserFilter string `json:"user_filter" default:"(&(objectClass=person)(uid=%s))"`
+	// admin status is derived from group membership if set, %s is replaced with the escaped user DN
+	AdminFilter string `json:"admin_filter"`
+	GroupBaseDN string `json:"group_base_dn"`
+	// interval in seconds to sync users from directory, 0 to disable
+	SyncInterval int `json:"sync_interval" default:"600" validate:"min=0"`
+}
+
+// ldapAuthProvider authenticates users by binding to a LDAP directory
+type ldapAuthProvider struct {
+	opts     ldapOptions
+	db       *gorm.DB
+	sessions *SessionRegistry
+	auditor  *Auditor
+	log      *zap.SugaredLogger
+}
+
+func (p *ldapAuthProvider) Source() string {
+	return model.UserSourceLDAP
+}
+
+func (p *ldapAuthProvider) dial() (conn *ldap.Conn, err error) {
+	var u *url.URL
+	if u, err = url.Parse(p.opts.URL); err != nil {
+		return
+	}
+
+	// ServerName is only set by DialURL for ldaps://, StartTLS needs it for verification
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: p.opts.InsecureSkipVerify,
+	}
+
+	if p.opts.CAFile != "" {
+		var buf []byte
+		if buf, err = os.ReadFile(p.opts.CAFile); err != nil {
+			return
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(buf) {
+			err = errors.New("invalid ldap ca_file: " + p.opts.CAFile)
+			return
+		}
+	}
+
+	if conn, err = ldap.DialURL(p.opts.URL, ldap.DialWithTLSConfig(tlsConfig)); err != nil {
+		return
+	}
+
+	if p.opts.StartTLS {
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return
+		}
+	}
+
+	if err = p.bindService(conn); err != nil {
+		conn.Close()
+		return
+	}
+	return
+}
+
+func (p *ldapAuthProvider) bindService(conn *ldap.Conn) error {
+	if p.opts.BindDN == "" {
+		return conn.UnauthenticatedBind("")
+	}
+	return conn.Bind(p.opts.BindDN, p.opts.BindPassword)
+}
+
+// searchUser finds the DN of user, returns empty string if not found
+func (p *ldapAuthProvider) searchUser(conn *ldap.Conn, username string) (dn string, err error) {
+	var res *ldap.SearchResult
+	if res, err = conn.Search(ldap.NewSearchRequest(
+		p.opts.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(p.opts.UserFilter, ldap.EscapeFilter(username)),
+		[]string{"dn"},
+		nil,
+	)); err != nil {
+		return
+	}
+
+	switch len(res.Entries) {
+	case 0:
+		return
+	case 1:
+		dn = res.Entries[0].DN
+		return
+	default:
+		err = errors.New("multiple ldap entries found for user " + username)
+		return
+	}
+}
+
+// isAdmin checks if the user DN matches the admin filter
+func (p *ldapAuthProvider) isAdmin(conn *ldap.Conn, dn string) (admin bool, err error) {
+	baseDN := p.opts.GroupBaseDN
+	if baseDN == "" {
+		baseDN = p.opts.BaseDN
+	}
+
+	var res *ldap.SearchResult
+	if res, err = conn.Search(ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1, 0, false,
+		fmt.Sprintf(p.opts.AdminFilter, ldap.EscapeFilter(dn)),
+		[]string{"dn"},
+		nil,
+	)); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			return true, nil
+		}
+		return
+	}
+
+	admin = len(res.Entries) > 0
+	return
+}
+
+func (p *ldapAuthProvider) Authenticate(ctx context.Context, username string, password string) (user *model.User, err error) {
+	if !model.UserIDPattern.MatchString(username) || password == "" {
+		err = errInvalidCredentials
+		return
+	}
+
+	var conn *ldap.Conn
+	if conn, err = p.dial(); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var dn string
+	if dn, err = p.searchUser(conn, username); err != nil {
+		return
+	}
+	if dn == "" {
+		// removed or disabled in directory
+		if err = p.block(username); err != nil {
+			return
+		}
+		err = errInvalidCredentials
+		return
+	}
+
+	if err = conn.Bind(dn, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			err = errInvalidCredentials
+		}
+		return
+	}
+
+	// rebind as service account for group search
+	if err = p.bindService(conn); err != nil {
+		return
+	}
+
+	// blocked earlier by a transient search miss
+	if err = p.unblock(username); err != nil {
+		return
+	}
+
+	return p.syncUser(conn, username, dn)
+}
+
+// syncUser creates or updates the user found in directory
+func (p *ldapAuthProvider) syncUser(conn *ldap.Conn, username string, dn string) (user *model.User, err error) {
+	db := dao.Use(p.db)
+
+	manageAdmin := p.opts.AdminFilter != ""
+
+	var isAdmin bool
+	if manageAdmin {
+		if isAdmin, err = p.isAdmin(conn, dn); err != nil {
+			return
+		}
+	}
+
+	if user, err = db.User.Where(db.User.ID.Eq(username)).First(); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+
+		user = &model.User{
+			ID:        username,
+			CreatedAt: time.Now(),
+			VisitedAt: time.Now(),
+			IsAdmin:   isAdmin,
+			Source:    model.UserSourceLDAP,
+		}
+		if err = db.User.Create(user); err != nil {
+			return
+		}
+		p.log.With("username", username, "is_admin", isAdmin).Info("ldap user created")
+
+		p.auditor.Record(&model.AuditEvent{
+			Actor:  username,
+			Action: "user.create",
+			Target: username,
+			Reason: "ldap sign in",
+			After:  auditState(user),
+		})
+		return
+	}
+
+	if user.Source != model.UserSourceLDAP {
+		user, err = nil, errors.New("user "+username+" is not managed by ldap")
+		return
+	}
+
+	if manageAdmin && user.IsAdmin != isAdmin {
+		if _, err = db.User.Where(db.User.ID.Eq(username)).UpdateColumnSimple(db.User.IsAdmin.Value(isAdmin)); err != nil {
+			return
+		}
+		before := *user
+		user.IsAdmin = isAdmin
+		p.log.With("username", username, "is_admin", isAdmin).Info("ldap user updated")
+
+		p.auditor.Record(&model.AuditEvent{
+			Action: "user.update",
+			Target: username,
+			Reason: "ldap admin filter",
+			Before: auditState(before),
+			After:  auditState(user),
+		})
+	}
+	return
+}
+
+// block blocks the user managed by ldap and terminates live sessions
+func (p *ldapAuthProvider) block(username string) (err error) {
+	db := dao.Use(p.db)
+
+	var res gen.ResultInfo
+	if res, err = db.User.Where(
+		db.User.ID.Eq(username),
+		db.User.Source.Eq(model.UserSourceLDAP),
+		db.User.IsBlocked.Is(false),
+	).UpdateColumnSimple(
+		db.User.IsBlocked.Value(true),
+		db.User.BlockedBy.Value(model.UserSourceLDAP),
+	); err != nil {
+		return
+	}
+
+	if res.RowsAffected > 0 {
+		if err = RevokeUserTokens(p.db, username); err != nil {
+			return
+		}
+		p.sessions.TerminateUser(username, "user blocked")
+		p.log.With("username", username).Info("ldap user blocked")
+
+		p.auditor.Record(&model.AuditEvent{
+			Action: "user.block",
+			Target: username,
+			Reason: "not found in ldap directory",
+		})
+	}
+	return
+}
+
+// unblock unblocks the user blocked by ldap, users blocked by admins are kept blocked
+func (p *ldapAuthProvider) unblock(username string) (err error) {
+	db := dao.Use(p.db)
+
+	var res gen.ResultInfo
+	if res, err = db.User.Where(
+		db.User.ID.Eq(username),
+		db.User.Source.Eq(model.UserSourceLDAP),
+		db.User.IsBlocked.Is(true),
+		db.User.BlockedBy.Eq(model.UserSourceLDAP),
+	).UpdateColumnSimple(
+		db.User.IsBlocked.Value(false),
+		db.User.BlockedBy.Value(""),
+	); err != nil {
+		return
+	}
+
+	if res.RowsAffected > 0 {
+		p.log.With("username", username).Info("ldap user unblocked")
+
+		p.auditor.Record(&model.AuditEvent{
+			Action: "user.unblock",
+			Target: username,
+			Reason: "found in ldap directory",
+		})
+	}
+	return
+}
+
+// Sync blocks users no longer found in directory, unblocks users blocked by ldap and found again, and updates admin status
+func (p *ldapAuthProvider) Sync() (err error) {
+	db := dao.Use(p.db)
+
+	var users []*model.User
+	if users, err = db.User.Where(
+		db.User.Source.Eq(model.UserSourceLDAP),
+		db.User.Where(db.User.IsBlocked.Is(false)).Or(db.User.BlockedBy.Eq(model.UserSourceLDAP)),
+	).Find(); err != nil {
+		return
+	}
+
+	if len(users) == 0 {
+		return
+	}
+
+	var conn *ldap.Conn
+	if conn, err = p.dial(); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for _, user := range users {
+		var dn string
+		if dn, err = p.searchUser(conn, user.ID); err != nil {
+			return
+		}
+
+		if dn == "" {
+			if err = p.block(user.ID); err != nil {
+				return
+			}
+			continue
+		}
+
+		if err = p.unblock(user.ID); err != nil {
+			return
+		}
+
+		if _, err = p.syncUser(conn, user.ID, dn); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// RunSync syncs users periodically until ctx is done
+func (p *ldapAuthProvider) RunSync(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(p.opts.SyncInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Sync(); err != nil {
+				p.log.With("error", err).Error("ldap sync")
+			}
+		}
+	}
+}
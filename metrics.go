@@ -0,0 +1,220 @@
+package bunker
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/yankeguo/halt"
+	"github.com/yankeguo/ufx"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+var (
+	metricSSHAuthAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bunker_ssh_auth_attempts_total",
+		Help: "SSH authentication attempts by method, result and reason",
+	}, []string{"method", "result", "reason"})
+
+	metricSSHDialDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "bunker_ssh_dial_duration_seconds",
+		Help:    "Latency of dialing target servers",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	metricSSHDialFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "bunker_ssh_dial_failures_total",
+		Help: "Failures of dialing target servers",
+	})
+
+	metricSSHConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bunker_ssh_connections_active",
+		Help: "Active ssh connections from users",
+	})
+
+	metricSSHChannels = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bunker_ssh_channels_active",
+		Help: "Active proxied channels, including web terminals",
+	})
+
+	metricBytesProxied = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bunker_bytes_proxied_total",
+		Help: "Bytes proxied between users and target servers, in is from user to server",
+	}, []string{"direction"})
+
+	metricBytesIn  = metricBytesProxied.WithLabelValues("in")
+	metricBytesOut = metricBytesProxied.WithLabelValues("out")
+
+	metricPolicyDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bunker_policy_decisions_total",
+		Help: "Policy decisions by policy, mode and result, including dry run",
+	}, []string{"policy", "mode", "result"})
+
+	metricHTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bunker_http_requests_total",
+		Help: "HTTP requests by route and status code",
+	}, []string{"route", "code"})
+
+	metricHTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bunker_http_request_duration_seconds",
+		Help:    "Latency of HTTP requests by route",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route"})
+)
+
+// sshAuthReason classifies the error of ssh authentication into a metric label of low cardinality
+func sshAuthReason(err error) string {
+	var partial *ssh.PartialSuccessError
+
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.As(err, &partial):
+		return "mfa_required"
+	case errors.Is(err, errUnknownKey):
+		return "unknown_key"
+	case errors.Is(err, errUserBlocked):
+		return "user_blocked"
+	case errors.Is(err, errNoGrant):
+		return "no_grant"
+	case errors.Is(err, errGrantDenied):
+		return "grant_denied"
+	case errors.Is(err, errPolicyDenied):
+		return "policy_denied"
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return "server_not_found"
+	case errors.Is(err, errServerRequireMFA):
+		return "mfa_not_enrolled"
+	case errors.Is(err, errTOTPInvalid), errors.Is(err, errRecoveryCodeInvalid):
+		return "invalid_code"
+	case errors.Is(err, errMFALocked):
+		return "mfa_locked"
+	default:
+		return "denied"
+	}
+}
+
+func observeSSHAuth(method string, err error) {
+	var (
+		partial *ssh.PartialSuccessError
+		result  = "success"
+	)
+	if err != nil && !errors.As(err, &partial) {
+		result = "failure"
+	}
+	metricSSHAuthAttempts.WithLabelValues(method, result, sshAuthReason(err)).Inc()
+}
+
+// metricsRouter records requests of routes registered with HandleFunc
+type metricsRouter struct {
+	ufx.Router
+}
+
+func (r metricsRouter) HandleFunc(pattern string, fn ufx.HandlerFunc) {
+	r.Router.HandleFunc(pattern, func(c ufx.Context) {
+		start := time.Now()
+
+		defer func() {
+			code := http.StatusOK
+
+			// halt panics are recovered by ufx.Context, re-panic after recording
+			r := recover()
+			if r != nil {
+				e, ok := r.(error)
+				if !ok {
+					e = fmt.Errorf("panic: %v", r)
+				}
+				code = halt.GetStatusCode(e)
+			}
+
+			metricHTTPRequests.WithLabelValues(pattern, strconv.Itoa(code)).Inc()
+			metricHTTPDuration.WithLabelValues(pattern).Observe(time.Since(start).Seconds())
+
+			if r != nil {
+				panic(r)
+			}
+		}()
+
+		fn(c)
+	})
+}
+
+type metricsOptions struct {
+	// serve metrics on a separate address, e.g. "127.0.0.1:9100", instead of the backend
+	Listen string `json:"listen"`
+	// bearer token required to scrape metrics
+	Token string `json:"token"`
+}
+
+type MetricsOptions struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Conf      ufx.Conf
+	Router    ufx.Router
+	Logger    *zap.SugaredLogger
+}
+
+// InstallMetricsToRouter exposes metrics on metrics.listen, or on the backend if metrics.token is set,
+// metrics are not exposed without either
+func InstallMetricsToRouter(opts MetricsOptions) (err error) {
+	var p metricsOptions
+	if err = opts.Conf.Bind(&p, "metrics"); err != nil {
+		return
+	}
+
+	handler := promhttp.Handler()
+	if p.Token != "" {
+		handler = requireMetricsToken(p.Token, handler)
+	}
+
+	if p.Listen == "" {
+		if p.Token == "" {
+			opts.Logger.Warn("metrics not exposed, set metrics.listen or metrics.token")
+			return
+		}
+		opts.Router.ServeMux().Handle("/metrics", handler)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+
+	server := &http.Server{Addr: p.Listen, Handler: mux}
+
+	opts.Lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			ln, err := net.Listen("tcp", p.Listen)
+			if err != nil {
+				return err
+			}
+			go server.Serve(ln)
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return server.Shutdown(ctx)
+		},
+	})
+	return
+}
+
+func requireMetricsToken(token string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if bearer, ok := bearerToken(req); !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			http.Error(rw, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
@@ -0,0 +1,207 @@
+package bunker
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"gorm.io/gen"
+	"gorm.io/gorm"
+)
+
+const (
+	totpIssuer = "bunker"
+
+	recoveryCodeCount = 10
+
+	mfaChallengeTTL         = time.Minute * 5
+	mfaChallengeMaxAttempts = 5
+
+	// second factor is locked after mfaMaxFailures consecutive failures, across challenges and connections
+	mfaMaxFailures = 5
+	mfaLockout     = time.Minute * 15
+)
+
+var (
+	errTOTPInvalid         = errors.New("invalid totp code")
+	errRecoveryCodeInvalid = errors.New("invalid recovery code")
+	errMFALocked           = errors.New("too many failed attempts of second factor, try again later")
+)
+
+// VerifyUserTOTP checks the code against the TOTP secret of user, a code can only be used once
+func VerifyUserTOTP(_db *gorm.DB, user *model.User, code string) (err error) {
+	if user.TOTPSecret == "" {
+		err = errors.New("totp not enrolled")
+		return
+	}
+
+	step, ok := model.MatchTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		err = errTOTPInvalid
+		return
+	}
+
+	db := dao.Use(_db)
+
+	// consume the time step, rejects replay of the same code
+	var res gen.ResultInfo
+	if res, err = db.User.Where(
+		db.User.ID.Eq(user.ID),
+		db.User.TOTPSecret.Eq(user.TOTPSecret),
+		db.User.TOTPLastStep.Lt(step),
+	).UpdateColumnSimple(db.User.TOTPLastStep.Value(step)); err != nil {
+		return
+	}
+
+	if res.RowsAffected == 0 {
+		err = errTOTPInvalid
+		return
+	}
+
+	user.TOTPLastStep = step
+	return
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateRecoveryCodes replaces recovery codes of user, only hashes are stored
+func GenerateRecoveryCodes(_db *gorm.DB, userID string) (codes []string, err error) {
+	db := dao.Use(_db)
+
+	err = db.Transaction(func(tx *dao.Query) (err error) {
+		if _, err = tx.RecoveryCode.Where(tx.RecoveryCode.UserID.Eq(userID)).Delete(); err != nil {
+			return
+		}
+
+		for i := 0; i < recoveryCodeCount; i++ {
+			buf := make([]byte, 10)
+			rand.Read(buf)
+			code := strings.ToLower(base32.StdEncoding.EncodeToString(buf))
+			code = code[:8] + "-" + code[8:]
+
+			if err = tx.RecoveryCode.Create(&model.RecoveryCode{
+				ID:        hashRecoveryCode(code),
+				UserID:    userID,
+				CreatedAt: time.Now(),
+			}); err != nil {
+				return
+			}
+			codes = append(codes, code)
+		}
+		return
+	})
+	return
+}
+
+// UseRecoveryCode consumes a recovery code of user
+func UseRecoveryCode(_db *gorm.DB, user *model.User, code string) (err error) {
+	db := dao.Use(_db)
+
+	var res gen.ResultInfo
+	if res, err = db.RecoveryCode.Where(
+		db.RecoveryCode.ID.Eq(hashRecoveryCode(code)),
+		db.RecoveryCode.UserID.Eq(user.ID),
+		db.RecoveryCode.UsedAt.IsNull(),
+	).UpdateColumnSimple(db.RecoveryCode.UsedAt.Value(time.Now())); err != nil {
+		return
+	}
+
+	if res.RowsAffected == 0 {
+		err = errRecoveryCodeInvalid
+		return
+	}
+	return
+}
+
+// VerifyUserMFA checks the second factor of user, either a TOTP code or a recovery code,
+// consecutive failures lock the second factor of user for a while
+func VerifyUserMFA(_db *gorm.DB, user *model.User, code string) (err error) {
+	if !user.TOTPEnabled {
+		err = errors.New("totp not enabled")
+		return
+	}
+
+	now := time.Now()
+
+	if user.MFALockedUntil != nil && user.MFALockedUntil.After(now) {
+		err = errMFALocked
+		return
+	}
+
+	if len(normalizeRecoveryCode(code)) == model.TOTPDigits {
+		err = VerifyUserTOTP(_db, user, code)
+	} else {
+		err = UseRecoveryCode(_db, user, code)
+	}
+
+	if errors.Is(err, errTOTPInvalid) || errors.Is(err, errRecoveryCodeInvalid) {
+		if lockErr := recordMFAFailure(_db, user, now); lockErr != nil {
+			err = lockErr
+		}
+		return
+	}
+
+	if err == nil && (user.MFAFailures > 0 || user.MFALockedUntil != nil) {
+		db := dao.Use(_db)
+		_, err = db.User.Where(db.User.ID.Eq(user.ID)).UpdateColumnSimple(
+			db.User.MFAFailures.Value(0),
+			db.User.MFALockedUntil.Null(),
+		)
+	}
+	return
+}
+
+// recordMFAFailure counts a failed attempt, and locks the second factor once mfaMaxFailures is reached
+func recordMFAFailure(_db *gorm.DB, user *model.User, now time.Time) (err error) {
+	db := dao.Use(_db)
+
+	if _, err = db.User.Where(db.User.ID.Eq(user.ID)).UpdateSimple(db.User.MFAFailures.Add(1)); err != nil {
+		return
+	}
+
+	if user, err = db.User.Where(db.User.ID.Eq(user.ID)).First(); err != nil {
+		return
+	}
+
+	if user.MFAFailures < mfaMaxFailures {
+		return
+	}
+
+	_, err = db.User.Where(db.User.ID.Eq(user.ID)).UpdateColumnSimple(
+		db.User.MFAFailures.Value(0),
+		db.User.MFALockedUntil.Value(now.Add(mfaLockout)),
+	)
+	return
+}
+
+// ResetUserMFA disables TOTP and removes recovery codes of user
+func ResetUserMFA(_db *gorm.DB, userID string) (err error) {
+	db := dao.Use(_db)
+
+	return db.Transaction(func(tx *dao.Query) (err error) {
+		if _, err = tx.User.Where(tx.User.ID.Eq(userID)).UpdateColumnSimple(
+			tx.User.TOTPEnabled.Value(false),
+			tx.User.TOTPSecret.Value(""),
+			tx.User.TOTPLastStep.Value(0),
+			tx.User.MFAFailures.Value(0),
+			tx.User.MFALockedUntil.Null(),
+		); err != nil {
+			return
+		}
+		_, err = tx.RecoveryCode.Where(tx.RecoveryCode.UserID.Eq(userID)).Delete()
+		return
+	})
+}
@@ -0,0 +1,248 @@
+package bunker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"gorm.io/gorm"
+)
+
+func createTestTOTPUser(t *testing.T, _db *gorm.DB) *model.User {
+	t.Helper()
+
+	user := &model.User{
+		ID:          "alice",
+		CreatedAt:   time.Now(),
+		VisitedAt:   time.Now(),
+		TOTPEnabled: true,
+		TOTPSecret:  model.GenerateTOTPSecret(),
+	}
+	if err := dao.Use(_db).User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func reloadTestUser(t *testing.T, _db *gorm.DB, userID string) *model.User {
+	t.Helper()
+
+	db := dao.Use(_db)
+	user, err := db.User.Where(db.User.ID.Eq(userID)).First()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func currentTestTOTPCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+
+	code, err := model.TOTPCode(secret, model.TOTPStep(time.Now())+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestVerifyUserTOTP(t *testing.T) {
+	tests := []struct {
+		name string
+		// time steps of codes relative to now, verified in order
+		offsets []int64
+		errs    []error
+	}{
+		{"valid", []int64{0}, []error{nil}},
+		{"previous step within skew", []int64{-1}, []error{nil}},
+		{"replayed", []int64{0, 0}, []error{nil, errTOTPInvalid}},
+		{"older after newer", []int64{1, 0}, []error{nil, errTOTPInvalid}},
+		{"newer after older", []int64{-1, 0}, []error{nil, nil}},
+		{"outside skew", []int64{5}, []error{errTOTPInvalid}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := createTestDatabase(t)
+			user := createTestTOTPUser(t, db)
+
+			for i, offset := range tt.offsets {
+				if err := VerifyUserTOTP(db, user, currentTestTOTPCode(t, user.TOTPSecret, offset)); !errors.Is(err, tt.errs[i]) {
+					t.Fatalf("attempt %d: expected %v, got %v", i, tt.errs[i], err)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyUserTOTPMalformed(t *testing.T) {
+	db := createTestDatabase(t)
+	user := createTestTOTPUser(t, db)
+
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if err := VerifyUserTOTP(db, user, code); !errors.Is(err, errTOTPInvalid) {
+			t.Fatalf("code %q: expected %v, got %v", code, errTOTPInvalid, err)
+		}
+	}
+}
+
+func TestVerifyUserTOTPNotEnrolled(t *testing.T) {
+	db := createTestDatabase(t)
+	user := createTestTOTPUser(t, db)
+	user.TOTPSecret = ""
+
+	if err := VerifyUserTOTP(db, user, "123456"); err == nil {
+		t.Fatal("expected error when totp not enrolled")
+	}
+}
+
+func createTestMFAUser(t *testing.T, _db *gorm.DB) (user *model.User, codes []string) {
+	t.Helper()
+
+	user = createTestTOTPUser(t, _db)
+
+	codes, err := GenerateRecoveryCodes(_db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+const testUnknownRecoveryCode = "aaaaaaaa-bbbbbbbb"
+
+func TestVerifyUserMFA(t *testing.T) {
+	tests := []struct {
+		name string
+		// returns codes to verify in order, with expected errors
+		run func(t *testing.T, user *model.User, recoveryCodes []string) (codes []string, errs []error)
+	}{
+		{
+			name: "valid totp code",
+			run: func(t *testing.T, user *model.User, _ []string) ([]string, []error) {
+				return []string{currentTestTOTPCode(t, user.TOTPSecret, 0)}, []error{nil}
+			},
+		},
+		{
+			name: "totp code replayed",
+			run: func(t *testing.T, user *model.User, _ []string) ([]string, []error) {
+				code := currentTestTOTPCode(t, user.TOTPSecret, 0)
+				return []string{code, code}, []error{nil, errTOTPInvalid}
+			},
+		},
+		{
+			name: "invalid totp code",
+			run: func(t *testing.T, user *model.User, _ []string) ([]string, []error) {
+				return []string{currentTestTOTPCode(t, user.TOTPSecret, 5)}, []error{errTOTPInvalid}
+			},
+		},
+		{
+			name: "recovery code used once",
+			run: func(t *testing.T, _ *model.User, recoveryCodes []string) ([]string, []error) {
+				return []string{recoveryCodes[0], recoveryCodes[0]}, []error{nil, errRecoveryCodeInvalid}
+			},
+		},
+		{
+			name: "recovery code normalized",
+			run: func(t *testing.T, _ *model.User, recoveryCodes []string) ([]string, []error) {
+				return []string{" " + strings.ToUpper(recoveryCodes[1]) + " "}, []error{nil}
+			},
+		},
+		{
+			name: "unknown recovery code",
+			run: func(t *testing.T, _ *model.User, _ []string) ([]string, []error) {
+				return []string{testUnknownRecoveryCode}, []error{errRecoveryCodeInvalid}
+			},
+		},
+		{
+			name: "locked after max failures",
+			run: func(t *testing.T, user *model.User, recoveryCodes []string) (codes []string, errs []error) {
+				for i := 0; i < mfaMaxFailures; i++ {
+					codes = append(codes, testUnknownRecoveryCode)
+					errs = append(errs, errRecoveryCodeInvalid)
+				}
+				codes = append(codes, recoveryCodes[0], currentTestTOTPCode(t, user.TOTPSecret, 0))
+				errs = append(errs, errMFALocked, errMFALocked)
+				return
+			},
+		},
+		{
+			name: "failures of totp and recovery codes counted together",
+			run: func(t *testing.T, user *model.User, recoveryCodes []string) (codes []string, errs []error) {
+				for i := 0; i < mfaMaxFailures; i++ {
+					if i%2 == 0 {
+						codes = append(codes, currentTestTOTPCode(t, user.TOTPSecret, 5))
+						errs = append(errs, errTOTPInvalid)
+					} else {
+						codes = append(codes, testUnknownRecoveryCode)
+						errs = append(errs, errRecoveryCodeInvalid)
+					}
+				}
+				codes = append(codes, recoveryCodes[0])
+				errs = append(errs, errMFALocked)
+				return
+			},
+		},
+		{
+			name: "success resets failures",
+			run: func(t *testing.T, user *model.User, recoveryCodes []string) (codes []string, errs []error) {
+				for _, recoveryCode := range recoveryCodes[:2] {
+					for i := 0; i < mfaMaxFailures-1; i++ {
+						codes = append(codes, testUnknownRecoveryCode)
+						errs = append(errs, errRecoveryCodeInvalid)
+					}
+					codes = append(codes, recoveryCode)
+					errs = append(errs, nil)
+				}
+				return
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := createTestDatabase(t)
+			user, recoveryCodes := createTestMFAUser(t, db)
+
+			codes, errs := tt.run(t, user, recoveryCodes)
+
+			for i, code := range codes {
+				// reload as callers do, state of second factor is persisted
+				user = reloadTestUser(t, db, user.ID)
+
+				if err := VerifyUserMFA(db, user, code); !errors.Is(err, errs[i]) {
+					t.Fatalf("attempt %d: expected %v, got %v", i, errs[i], err)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyUserMFANotEnabled(t *testing.T) {
+	db := createTestDatabase(t)
+	user, recoveryCodes := createTestMFAUser(t, db)
+	user.TOTPEnabled = false
+
+	if err := VerifyUserMFA(db, user, recoveryCodes[0]); err == nil {
+		t.Fatal("expected error when totp not enabled")
+	}
+}
+
+func TestVerifyUserMFALockExpires(t *testing.T) {
+	db := createTestDatabase(t)
+	user, recoveryCodes := createTestMFAUser(t, db)
+
+	expired := time.Now().Add(-time.Second)
+	user.MFALockedUntil = &expired
+	user.MFAFailures = 3
+
+	if err := VerifyUserMFA(db, user, recoveryCodes[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	user = reloadTestUser(t, db, user.ID)
+	if user.MFAFailures != 0 || user.MFALockedUntil != nil {
+		t.Fatalf("expected failures reset, got %d, %v", user.MFAFailures, user.MFALockedUntil)
+	}
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+const (
+	AccessRequestPending  = "pending"
+	AccessRequestApproved = "approved"
+	AccessRequestDenied   = "denied"
+	AccessRequestCanceled = "canceled"
+)
+
+// AccessRequest is a request of user for a temporary grant, reviewed by admins
+type AccessRequest struct {
+	ID            string `gorm:"column:id;primaryKey" json:"id"`
+	UserID        string `gorm:"column:user_id;not null;index" json:"user_id"`
+	ServerUser    string `gorm:"column:server_user;not null" json:"server_user"`
+	ServerID      string `gorm:"column:server_id;not null" json:"server_id"`
+	Justification string `gorm:"column:justification;not null" json:"justification"`
+	// requested duration of grant in seconds
+	Duration int `gorm:"column:duration;not null" json:"duration"`
+	// see AccessRequest constants
+	Status        string     `gorm:"column:status;not null;index" json:"status"`
+	ReviewerID    string     `gorm:"column:reviewer_id;not null;default:''" json:"reviewer_id"`
+	ReviewComment string     `gorm:"column:review_comment;not null;default:''" json:"review_comment"`
+	GrantID       string     `gorm:"column:grant_id;not null;default:''" json:"grant_id"`
+	CreatedAt     time.Time  `gorm:"column:created_at;not null;index" json:"created_at"`
+	ReviewedAt    *time.Time `gorm:"column:reviewed_at" json:"reviewed_at"`
+}
@@ -4,6 +4,18 @@ var All = []any{
 	User{},
 	Key{},
 	Server{},
+	ServerLabel{},
 	Grant{},
 	Token{},
+	Session{},
+	MFAChallenge{},
+	RecoveryCode{},
+	APIToken{},
+	AuditEvent{},
+	Webhook{},
+	WebhookDelivery{},
+	AccessRequest{},
+	Group{},
+	GroupMember{},
+	Policy{},
 }
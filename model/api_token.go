@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+const (
+	// APITokenScopeRead allows read-only routes
+	APITokenScopeRead = "read"
+	// APITokenScopeWrite allows all routes except admin routes
+	APITokenScopeWrite = "write"
+	// APITokenScopeAdmin allows all routes
+	APITokenScopeAdmin = "admin"
+)
+
+// APIToken is a personal access token, accepted as bearer token
+type APIToken struct {
+	// sha256 of token
+	ID     string `gorm:"column:id;primarykey" json:"id"`
+	UserID string `gorm:"column:user_id;not null;index" json:"user_id"`
+	Name   string `gorm:"column:name;not null" json:"name"`
+	// leading characters of token, for display only
+	Prefix    string     `gorm:"column:prefix;not null" json:"prefix"`
+	Scope     string     `gorm:"column:scope;not null" json:"scope"`
+	CreatedAt time.Time  `gorm:"column:created_at;not null;index" json:"created_at"`
+	ExpiresAt *time.Time `gorm:"column:expires_at;index" json:"expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+)
+
+// AuditEvent records an administrative or security event
+type AuditEvent struct {
+	ID int64 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	// user performing the action, empty for bunker itself
+	Actor string `gorm:"column:actor;not null;index" json:"actor"`
+	// dot separated, e.g. grant.create, ssh.auth
+	Action string `gorm:"column:action;not null;index" json:"action"`
+	Target string `gorm:"column:target;not null;index" json:"target"`
+	// see AuditResult constants
+	Result string `gorm:"column:result;not null;index" json:"result"`
+	Reason string `gorm:"column:reason;not null;default:''" json:"reason"`
+	// json encoded state of target before and after the action
+	Before    json.RawMessage `gorm:"column:before_state" json:"before"`
+	After     json.RawMessage `gorm:"column:after_state" json:"after"`
+	SourceIP  string          `gorm:"column:source_ip;not null;default:''" json:"source_ip"`
+	CreatedAt time.Time       `gorm:"column:created_at;not null;index" json:"created_at"`
+}
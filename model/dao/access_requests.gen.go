@@ -0,0 +1,363 @@
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/yankeguo/bunker/model"
+)
+
+func newAccessRequest(db *gorm.DB, opts ...gen.DOOption) accessRequest {
+	_accessRequest := accessRequest{}
+
+	_accessRequest.accessRequestDo.UseDB(db, opts...)
+	_accessRequest.accessRequestDo.UseModel(&model.AccessRequest{})
+
+	tableName := _accessRequest.accessRequestDo.TableName()
+	_accessRequest.ALL = field.NewAsterisk(tableName)
+	_accessRequest.ID = field.NewString(tableName, "id")
+	_accessRequest.UserID = field.NewString(tableName, "user_id")
+	_accessRequest.ServerUser = field.NewString(tableName, "server_user")
+	_accessRequest.ServerID = field.NewString(tableName, "server_id")
+	_accessRequest.Justification = field.NewString(tableName, "justification")
+	_accessRequest.Duration = field.NewInt(tableName, "duration")
+	_accessRequest.Status = field.NewString(tableName, "status")
+	_accessRequest.ReviewerID = field.NewString(tableName, "reviewer_id")
+	_accessRequest.ReviewComment = field.NewString(tableName, "review_comment")
+	_accessRequest.GrantID = field.NewString(tableName, "grant_id")
+	_accessRequest.CreatedAt = field.NewTime(tableName, "created_at")
+	_accessRequest.ReviewedAt = field.NewTime(tableName, "reviewed_at")
+
+	_accessRequest.fillFieldMap()
+
+	return _accessRequest
+}
+
+type accessRequest struct {
+	accessRequestDo
+
+	ALL           field.Asterisk
+	ID            field.String
+	UserID        field.String
+	ServerUser    field.String
+	ServerID      field.String
+	Justification field.String
+	Duration      field.Int
+	Status        field.String
+	ReviewerID    field.String
+	ReviewComment field.String
+	GrantID       field.String
+	CreatedAt     field.Time
+	ReviewedAt    field.Time
+
+	fieldMap map[string]field.Expr
+}
+
+func (a accessRequest) Table(newTableName string) *accessRequest {
+	a.accessRequestDo.UseTable(newTableName)
+	return a.updateTableName(newTableName)
+}
+
+func (a accessRequest) As(alias string) *accessRequest {
+	a.accessRequestDo.DO = *(a.accessRequestDo.As(alias).(*gen.DO))
+	return a.updateTableName(alias)
+}
+
+func (a *accessRequest) updateTableName(table string) *accessRequest {
+	a.ALL = field.NewAsterisk(table)
+	a.ID = field.NewString(table, "id")
+	a.UserID = field.NewString(table, "user_id")
+	a.ServerUser = field.NewString(table, "server_user")
+	a.ServerID = field.NewString(table, "server_id")
+	a.Justification = field.NewString(table, "justification")
+	a.Duration = field.NewInt(table, "duration")
+	a.Status = field.NewString(table, "status")
+	a.ReviewerID = field.NewString(table, "reviewer_id")
+	a.ReviewComment = field.NewString(table, "review_comment")
+	a.GrantID = field.NewString(table, "grant_id")
+	a.CreatedAt = field.NewTime(table, "created_at")
+	a.ReviewedAt = field.NewTime(table, "reviewed_at")
+
+	a.fillFieldMap()
+
+	return a
+}
+
+func (a *accessRequest) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := a.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (a *accessRequest) fillFieldMap() {
+	a.fieldMap = make(map[string]field.Expr, 12)
+	a.fieldMap["id"] = a.ID
+	a.fieldMap["user_id"] = a.UserID
+	a.fieldMap["server_user"] = a.ServerUser
+	a.fieldMap["server_id"] = a.ServerID
+	a.fieldMap["justification"] = a.Justification
+	a.fieldMap["duration"] = a.Duration
+	a.fieldMap["status"] = a.Status
+	a.fieldMap["reviewer_id"] = a.ReviewerID
+	a.fieldMap["review_comment"] = a.ReviewComment
+	a.fieldMap["grant_id"] = a.GrantID
+	a.fieldMap["created_at"] = a.CreatedAt
+	a.fieldMap["reviewed_at"] = a.ReviewedAt
+}
+
+func (a accessRequest) clone(db *gorm.DB) accessRequest {
+	a.accessRequestDo.ReplaceConnPool(db.Statement.ConnPool)
+	return a
+}
+
+func (a accessRequest) replaceDB(db *gorm.DB) accessRequest {
+	a.accessRequestDo.ReplaceDB(db)
+	return a
+}
+
+type accessRequestDo struct{ gen.DO }
+
+func (a accessRequestDo) Debug() *accessRequestDo {
+	return a.withDO(a.DO.Debug())
+}
+
+func (a accessRequestDo) WithContext(ctx context.Context) *accessRequestDo {
+	return a.withDO(a.DO.WithContext(ctx))
+}
+
+func (a accessRequestDo) ReadDB() *accessRequestDo {
+	return a.Clauses(dbresolver.Read)
+}
+
+func (a accessRequestDo) WriteDB() *accessRequestDo {
+	return a.Clauses(dbresolver.Write)
+}
+
+func (a accessRequestDo) Session(config *gorm.Session) *accessRequestDo {
+	return a.withDO(a.DO.Session(config))
+}
+
+func (a accessRequestDo) Clauses(conds ...clause.Expression) *accessRequestDo {
+	return a.withDO(a.DO.Clauses(conds...))
+}
+
+func (a accessRequestDo) Returning(value interface{}, columns ...string) *accessRequestDo {
+	return a.withDO(a.DO.Returning(value, columns...))
+}
+
+func (a accessRequestDo) Not(conds ...gen.Condition) *accessRequestDo {
+	return a.withDO(a.DO.Not(conds...))
+}
+
+func (a accessRequestDo) Or(conds ...gen.Condition) *accessRequestDo {
+	return a.withDO(a.DO.Or(conds...))
+}
+
+func (a accessRequestDo) Select(conds ...field.Expr) *accessRequestDo {
+	return a.withDO(a.DO.Select(conds...))
+}
+
+func (a accessRequestDo) Where(conds ...gen.Condition) *accessRequestDo {
+	return a.withDO(a.DO.Where(conds...))
+}
+
+func (a accessRequestDo) Order(conds ...field.Expr) *accessRequestDo {
+	return a.withDO(a.DO.Order(conds...))
+}
+
+func (a accessRequestDo) Distinct(cols ...field.Expr) *accessRequestDo {
+	return a.withDO(a.DO.Distinct(cols...))
+}
+
+func (a accessRequestDo) Omit(cols ...field.Expr) *accessRequestDo {
+	return a.withDO(a.DO.Omit(cols...))
+}
+
+func (a accessRequestDo) Join(table schema.Tabler, on ...field.Expr) *accessRequestDo {
+	return a.withDO(a.DO.Join(table, on...))
+}
+
+func (a accessRequestDo) LeftJoin(table schema.Tabler, on ...field.Expr) *accessRequestDo {
+	return a.withDO(a.DO.LeftJoin(table, on...))
+}
+
+func (a accessRequestDo) RightJoin(table schema.Tabler, on ...field.Expr) *accessRequestDo {
+	return a.withDO(a.DO.RightJoin(table, on...))
+}
+
+func (a accessRequestDo) Group(cols ...field.Expr) *accessRequestDo {
+	return a.withDO(a.DO.Group(cols...))
+}
+
+func (a accessRequestDo) Having(conds ...gen.Condition) *accessRequestDo {
+	return a.withDO(a.DO.Having(conds...))
+}
+
+func (a accessRequestDo) Limit(limit int) *accessRequestDo {
+	return a.withDO(a.DO.Limit(limit))
+}
+
+func (a accessRequestDo) Offset(offset int) *accessRequestDo {
+	return a.withDO(a.DO.Offset(offset))
+}
+
+func (a accessRequestDo) Scopes(funcs ...func(gen.Dao) gen.Dao) *accessRequestDo {
+	return a.withDO(a.DO.Scopes(funcs...))
+}
+
+func (a accessRequestDo) Unscoped() *accessRequestDo {
+	return a.withDO(a.DO.Unscoped())
+}
+
+func (a accessRequestDo) Create(values ...*model.AccessRequest) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return a.DO.Create(values)
+}
+
+func (a accessRequestDo) CreateInBatches(values []*model.AccessRequest, batchSize int) error {
+	return a.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (a accessRequestDo) Save(values ...*model.AccessRequest) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return a.DO.Save(values)
+}
+
+func (a accessRequestDo) First() (*model.AccessRequest, error) {
+	if result, err := a.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.AccessRequest), nil
+	}
+}
+
+func (a accessRequestDo) Take() (*model.AccessRequest, error) {
+	if result, err := a.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.AccessRequest), nil
+	}
+}
+
+func (a accessRequestDo) Last() (*model.AccessRequest, error) {
+	if result, err := a.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.AccessRequest), nil
+	}
+}
+
+func (a accessRequestDo) Find() ([]*model.AccessRequest, error) {
+	result, err := a.DO.Find()
+	return result.([]*model.AccessRequest), err
+}
+
+func (a accessRequestDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.AccessRequest, err error) {
+	buf := make([]*model.AccessRequest, 0, batchSize)
+	err = a.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (a accessRequestDo) FindInBatches(result *[]*model.AccessRequest, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return a.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (a accessRequestDo) Attrs(attrs ...field.AssignExpr) *accessRequestDo {
+	return a.withDO(a.DO.Attrs(attrs...))
+}
+
+func (a accessRequestDo) Assign(attrs ...field.AssignExpr) *accessRequestDo {
+	return a.withDO(a.DO.Assign(attrs...))
+}
+
+func (a accessRequestDo) Joins(fields ...field.RelationField) *accessRequestDo {
+	for _, _f := range fields {
+		a = *a.withDO(a.DO.Joins(_f))
+	}
+	return &a
+}
+
+func (a accessRequestDo) Preload(fields ...field.RelationField) *accessRequestDo {
+	for _, _f := range fields {
+		a = *a.withDO(a.DO.Preload(_f))
+	}
+	return &a
+}
+
+func (a accessRequestDo) FirstOrInit() (*model.AccessRequest, error) {
+	if result, err := a.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.AccessRequest), nil
+	}
+}
+
+func (a accessRequestDo) FirstOrCreate() (*model.AccessRequest, error) {
+	if result, err := a.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.AccessRequest), nil
+	}
+}
+
+func (a accessRequestDo) FindByPage(offset int, limit int) (result []*model.AccessRequest, count int64, err error) {
+	result, err = a.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = a.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (a accessRequestDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = a.Count()
+	if err != nil {
+		return
+	}
+
+	err = a.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (a accessRequestDo) Scan(result interface{}) (err error) {
+	return a.DO.Scan(result)
+}
+
+func (a accessRequestDo) Delete(models ...*model.AccessRequest) (result gen.ResultInfo, err error) {
+	return a.DO.Delete(models)
+}
+
+func (a *accessRequestDo) withDO(do gen.Dao) *accessRequestDo {
+	a.DO = *do.(*gen.DO)
+	return a
+}
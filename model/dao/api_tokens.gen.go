@@ -0,0 +1,347 @@
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/yankeguo/bunker/model"
+)
+
+func newAPIToken(db *gorm.DB, opts ...gen.DOOption) aPIToken {
+	_aPIToken := aPIToken{}
+
+	_aPIToken.aPITokenDo.UseDB(db, opts...)
+	_aPIToken.aPITokenDo.UseModel(&model.APIToken{})
+
+	tableName := _aPIToken.aPITokenDo.TableName()
+	_aPIToken.ALL = field.NewAsterisk(tableName)
+	_aPIToken.ID = field.NewString(tableName, "id")
+	_aPIToken.UserID = field.NewString(tableName, "user_id")
+	_aPIToken.Name = field.NewString(tableName, "name")
+	_aPIToken.Prefix = field.NewString(tableName, "prefix")
+	_aPIToken.Scope = field.NewString(tableName, "scope")
+	_aPIToken.CreatedAt = field.NewTime(tableName, "created_at")
+	_aPIToken.ExpiresAt = field.NewTime(tableName, "expires_at")
+	_aPIToken.UsedAt = field.NewTime(tableName, "used_at")
+
+	_aPIToken.fillFieldMap()
+
+	return _aPIToken
+}
+
+type aPIToken struct {
+	aPITokenDo
+
+	ALL       field.Asterisk
+	ID        field.String
+	UserID    field.String
+	Name      field.String
+	Prefix    field.String
+	Scope     field.String
+	CreatedAt field.Time
+	ExpiresAt field.Time
+	UsedAt    field.Time
+
+	fieldMap map[string]field.Expr
+}
+
+func (a aPIToken) Table(newTableName string) *aPIToken {
+	a.aPITokenDo.UseTable(newTableName)
+	return a.updateTableName(newTableName)
+}
+
+func (a aPIToken) As(alias string) *aPIToken {
+	a.aPITokenDo.DO = *(a.aPITokenDo.As(alias).(*gen.DO))
+	return a.updateTableName(alias)
+}
+
+func (a *aPIToken) updateTableName(table string) *aPIToken {
+	a.ALL = field.NewAsterisk(table)
+	a.ID = field.NewString(table, "id")
+	a.UserID = field.NewString(table, "user_id")
+	a.Name = field.NewString(table, "name")
+	a.Prefix = field.NewString(table, "prefix")
+	a.Scope = field.NewString(table, "scope")
+	a.CreatedAt = field.NewTime(table, "created_at")
+	a.ExpiresAt = field.NewTime(table, "expires_at")
+	a.UsedAt = field.NewTime(table, "used_at")
+
+	a.fillFieldMap()
+
+	return a
+}
+
+func (a *aPIToken) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := a.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (a *aPIToken) fillFieldMap() {
+	a.fieldMap = make(map[string]field.Expr, 8)
+	a.fieldMap["id"] = a.ID
+	a.fieldMap["user_id"] = a.UserID
+	a.fieldMap["name"] = a.Name
+	a.fieldMap["prefix"] = a.Prefix
+	a.fieldMap["scope"] = a.Scope
+	a.fieldMap["created_at"] = a.CreatedAt
+	a.fieldMap["expires_at"] = a.ExpiresAt
+	a.fieldMap["used_at"] = a.UsedAt
+}
+
+func (a aPIToken) clone(db *gorm.DB) aPIToken {
+	a.aPITokenDo.ReplaceConnPool(db.Statement.ConnPool)
+	return a
+}
+
+func (a aPIToken) replaceDB(db *gorm.DB) aPIToken {
+	a.aPITokenDo.ReplaceDB(db)
+	return a
+}
+
+type aPITokenDo struct{ gen.DO }
+
+func (a aPITokenDo) Debug() *aPITokenDo {
+	return a.withDO(a.DO.Debug())
+}
+
+func (a aPITokenDo) WithContext(ctx context.Context) *aPITokenDo {
+	return a.withDO(a.DO.WithContext(ctx))
+}
+
+func (a aPITokenDo) ReadDB() *aPITokenDo {
+	return a.Clauses(dbresolver.Read)
+}
+
+func (a aPITokenDo) WriteDB() *aPITokenDo {
+	return a.Clauses(dbresolver.Write)
+}
+
+func (a aPITokenDo) Session(config *gorm.Session) *aPITokenDo {
+	return a.withDO(a.DO.Session(config))
+}
+
+func (a aPITokenDo) Clauses(conds ...clause.Expression) *aPITokenDo {
+	return a.withDO(a.DO.Clauses(conds...))
+}
+
+func (a aPITokenDo) Returning(value interface{}, columns ...string) *aPITokenDo {
+	return a.withDO(a.DO.Returning(value, columns...))
+}
+
+func (a aPITokenDo) Not(conds ...gen.Condition) *aPITokenDo {
+	return a.withDO(a.DO.Not(conds...))
+}
+
+func (a aPITokenDo) Or(conds ...gen.Condition) *aPITokenDo {
+	return a.withDO(a.DO.Or(conds...))
+}
+
+func (a aPITokenDo) Select(conds ...field.Expr) *aPITokenDo {
+	return a.withDO(a.DO.Select(conds...))
+}
+
+func (a aPITokenDo) Where(conds ...gen.Condition) *aPITokenDo {
+	return a.withDO(a.DO.Where(conds...))
+}
+
+func (a aPITokenDo) Order(conds ...field.Expr) *aPITokenDo {
+	return a.withDO(a.DO.Order(conds...))
+}
+
+func (a aPITokenDo) Distinct(cols ...field.Expr) *aPITokenDo {
+	return a.withDO(a.DO.Distinct(cols...))
+}
+
+func (a aPITokenDo) Omit(cols ...field.Expr) *aPITokenDo {
+	return a.withDO(a.DO.Omit(cols...))
+}
+
+func (a aPITokenDo) Join(table schema.Tabler, on ...field.Expr) *aPITokenDo {
+	return a.withDO(a.DO.Join(table, on...))
+}
+
+func (a aPITokenDo) LeftJoin(table schema.Tabler, on ...field.Expr) *aPITokenDo {
+	return a.withDO(a.DO.LeftJoin(table, on...))
+}
+
+func (a aPITokenDo) RightJoin(table schema.Tabler, on ...field.Expr) *aPITokenDo {
+	return a.withDO(a.DO.RightJoin(table, on...))
+}
+
+func (a aPITokenDo) Group(cols ...field.Expr) *aPITokenDo {
+	return a.withDO(a.DO.Group(cols...))
+}
+
+func (a aPITokenDo) Having(conds ...gen.Condition) *aPITokenDo {
+	return a.withDO(a.DO.Having(conds...))
+}
+
+func (a aPITokenDo) Limit(limit int) *aPITokenDo {
+	return a.withDO(a.DO.Limit(limit))
+}
+
+func (a aPITokenDo) Offset(offset int) *aPITokenDo {
+	return a.withDO(a.DO.Offset(offset))
+}
+
+func (a aPITokenDo) Scopes(funcs ...func(gen.Dao) gen.Dao) *aPITokenDo {
+	return a.withDO(a.DO.Scopes(funcs...))
+}
+
+func (a aPITokenDo) Unscoped() *aPITokenDo {
+	return a.withDO(a.DO.Unscoped())
+}
+
+func (a aPITokenDo) Create(values ...*model.APIToken) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return a.DO.Create(values)
+}
+
+func (a aPITokenDo) CreateInBatches(values []*model.APIToken, batchSize int) error {
+	return a.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (a aPITokenDo) Save(values ...*model.APIToken) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return a.DO.Save(values)
+}
+
+func (a aPITokenDo) First() (*model.APIToken, error) {
+	if result, err := a.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.APIToken), nil
+	}
+}
+
+func (a aPITokenDo) Take() (*model.APIToken, error) {
+	if result, err := a.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.APIToken), nil
+	}
+}
+
+func (a aPITokenDo) Last() (*model.APIToken, error) {
+	if result, err := a.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.APIToken), nil
+	}
+}
+
+func (a aPITokenDo) Find() ([]*model.APIToken, error) {
+	result, err := a.DO.Find()
+	return result.([]*model.APIToken), err
+}
+
+func (a aPITokenDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.APIToken, err error) {
+	buf := make([]*model.APIToken, 0, batchSize)
+	err = a.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (a aPITokenDo) FindInBatches(result *[]*model.APIToken, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return a.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (a aPITokenDo) Attrs(attrs ...field.AssignExpr) *aPITokenDo {
+	return a.withDO(a.DO.Attrs(attrs...))
+}
+
+func (a aPITokenDo) Assign(attrs ...field.AssignExpr) *aPITokenDo {
+	return a.withDO(a.DO.Assign(attrs...))
+}
+
+func (a aPITokenDo) Joins(fields ...field.RelationField) *aPITokenDo {
+	for _, _f := range fields {
+		a = *a.withDO(a.DO.Joins(_f))
+	}
+	return &a
+}
+
+func (a aPITokenDo) Preload(fields ...field.RelationField) *aPITokenDo {
+	for _, _f := range fields {
+		a = *a.withDO(a.DO.Preload(_f))
+	}
+	return &a
+}
+
+func (a aPITokenDo) FirstOrInit() (*model.APIToken, error) {
+	if result, err := a.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.APIToken), nil
+	}
+}
+
+func (a aPITokenDo) FirstOrCreate() (*model.APIToken, error) {
+	if result, err := a.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.APIToken), nil
+	}
+}
+
+func (a aPITokenDo) FindByPage(offset int, limit int) (result []*model.APIToken, count int64, err error) {
+	result, err = a.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = a.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (a aPITokenDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = a.Count()
+	if err != nil {
+		return
+	}
+
+	err = a.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (a aPITokenDo) Scan(result interface{}) (err error) {
+	return a.DO.Scan(result)
+}
+
+func (a aPITokenDo) Delete(models ...*model.APIToken) (result gen.ResultInfo, err error) {
+	return a.DO.Delete(models)
+}
+
+func (a *aPITokenDo) withDO(do gen.Dao) *aPITokenDo {
+	a.DO = *do.(*gen.DO)
+	return a
+}
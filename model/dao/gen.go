@@ -16,54 +16,114 @@ import (
 )
 
 var (
-	Q      = new(Query)
-	Grant  *grant
-	Key    *key
-	Server *server
-	Token  *token
-	User   *user
+	Q               = new(Query)
+	APIToken        *aPIToken
+	AccessRequest   *accessRequest
+	AuditEvent      *auditEvent
+	Grant           *grant
+	Group           *group
+	GroupMember     *groupMember
+	Key             *key
+	MFAChallenge    *mFAChallenge
+	Policy          *policy
+	RecoveryCode    *recoveryCode
+	Server          *server
+	ServerLabel     *serverLabel
+	Session         *session
+	Token           *token
+	User            *user
+	Webhook         *webhook
+	WebhookDelivery *webhookDelivery
 )
 
 func SetDefault(db *gorm.DB, opts ...gen.DOOption) {
 	*Q = *Use(db, opts...)
+	APIToken = &Q.APIToken
+	AccessRequest = &Q.AccessRequest
+	AuditEvent = &Q.AuditEvent
 	Grant = &Q.Grant
+	Group = &Q.Group
+	GroupMember = &Q.GroupMember
 	Key = &Q.Key
+	MFAChallenge = &Q.MFAChallenge
+	Policy = &Q.Policy
+	RecoveryCode = &Q.RecoveryCode
 	Server = &Q.Server
+	ServerLabel = &Q.ServerLabel
+	Session = &Q.Session
 	Token = &Q.Token
 	User = &Q.User
+	Webhook = &Q.Webhook
+	WebhookDelivery = &Q.WebhookDelivery
 }
 
 func Use(db *gorm.DB, opts ...gen.DOOption) *Query {
 	return &Query{
-		db:     db,
-		Grant:  newGrant(db, opts...),
-		Key:    newKey(db, opts...),
-		Server: newServer(db, opts...),
-		Token:  newToken(db, opts...),
-		User:   newUser(db, opts...),
+		db:              db,
+		APIToken:        newAPIToken(db, opts...),
+		AccessRequest:   newAccessRequest(db, opts...),
+		AuditEvent:      newAuditEvent(db, opts...),
+		Grant:           newGrant(db, opts...),
+		Group:           newGroup(db, opts...),
+		GroupMember:     newGroupMember(db, opts...),
+		Key:             newKey(db, opts...),
+		MFAChallenge:    newMFAChallenge(db, opts...),
+		Policy:          newPolicy(db, opts...),
+		RecoveryCode:    newRecoveryCode(db, opts...),
+		Server:          newServer(db, opts...),
+		ServerLabel:     newServerLabel(db, opts...),
+		Session:         newSession(db, opts...),
+		Token:           newToken(db, opts...),
+		User:            newUser(db, opts...),
+		Webhook:         newWebhook(db, opts...),
+		WebhookDelivery: newWebhookDelivery(db, opts...),
 	}
 }
 
 type Query struct {
 	db *gorm.DB
 
-	Grant  grant
-	Key    key
-	Server server
-	Token  token
-	User   user
+	APIToken        aPIToken
+	AccessRequest   accessRequest
+	AuditEvent      auditEvent
+	Grant           grant
+	Group           group
+	GroupMember     groupMember
+	Key             key
+	MFAChallenge    mFAChallenge
+	Policy          policy
+	RecoveryCode    recoveryCode
+	Server          server
+	ServerLabel     serverLabel
+	Session         session
+	Token           token
+	User            user
+	Webhook         webhook
+	WebhookDelivery webhookDelivery
 }
 
 func (q *Query) Available() bool { return q.db != nil }
 
 func (q *Query) clone(db *gorm.DB) *Query {
 	return &Query{
-		db:     db,
-		Grant:  q.Grant.clone(db),
-		Key:    q.Key.clone(db),
-		Server: q.Server.clone(db),
-		Token:  q.Token.clone(db),
-		User:   q.User.clone(db),
+		db:              db,
+		APIToken:        q.APIToken.clone(db),
+		AccessRequest:   q.AccessRequest.clone(db),
+		AuditEvent:      q.AuditEvent.clone(db),
+		Grant:           q.Grant.clone(db),
+		Group:           q.Group.clone(db),
+		GroupMember:     q.GroupMember.clone(db),
+		Key:             q.Key.clone(db),
+		MFAChallenge:    q.MFAChallenge.clone(db),
+		Policy:          q.Policy.clone(db),
+		RecoveryCode:    q.RecoveryCode.clone(db),
+		Server:          q.Server.clone(db),
+		ServerLabel:     q.ServerLabel.clone(db),
+		Session:         q.Session.clone(db),
+		Token:           q.Token.clone(db),
+		User:            q.User.clone(db),
+		Webhook:         q.Webhook.clone(db),
+		WebhookDelivery: q.WebhookDelivery.clone(db),
 	}
 }
 
@@ -77,30 +137,66 @@ func (q *Query) WriteDB() *Query {
 
 func (q *Query) ReplaceDB(db *gorm.DB) *Query {
 	return &Query{
-		db:     db,
-		Grant:  q.Grant.replaceDB(db),
-		Key:    q.Key.replaceDB(db),
-		Server: q.Server.replaceDB(db),
-		Token:  q.Token.replaceDB(db),
-		User:   q.User.replaceDB(db),
+		db:              db,
+		APIToken:        q.APIToken.replaceDB(db),
+		AccessRequest:   q.AccessRequest.replaceDB(db),
+		AuditEvent:      q.AuditEvent.replaceDB(db),
+		Grant:           q.Grant.replaceDB(db),
+		Group:           q.Group.replaceDB(db),
+		GroupMember:     q.GroupMember.replaceDB(db),
+		Key:             q.Key.replaceDB(db),
+		MFAChallenge:    q.MFAChallenge.replaceDB(db),
+		Policy:          q.Policy.replaceDB(db),
+		RecoveryCode:    q.RecoveryCode.replaceDB(db),
+		Server:          q.Server.replaceDB(db),
+		ServerLabel:     q.ServerLabel.replaceDB(db),
+		Session:         q.Session.replaceDB(db),
+		Token:           q.Token.replaceDB(db),
+		User:            q.User.replaceDB(db),
+		Webhook:         q.Webhook.replaceDB(db),
+		WebhookDelivery: q.WebhookDelivery.replaceDB(db),
 	}
 }
 
 type queryCtx struct {
-	Grant  *grantDo
-	Key    *keyDo
-	Server *serverDo
-	Token  *tokenDo
-	User   *userDo
+	APIToken        *aPITokenDo
+	AccessRequest   *accessRequestDo
+	AuditEvent      *auditEventDo
+	Grant           *grantDo
+	Group           *groupDo
+	GroupMember     *groupMemberDo
+	Key             *keyDo
+	MFAChallenge    *mFAChallengeDo
+	Policy          *policyDo
+	RecoveryCode    *recoveryCodeDo
+	Server          *serverDo
+	ServerLabel     *serverLabelDo
+	Session         *sessionDo
+	Token           *tokenDo
+	User            *userDo
+	Webhook         *webhookDo
+	WebhookDelivery *webhookDeliveryDo
 }
 
 func (q *Query) WithContext(ctx context.Context) *queryCtx {
 	return &queryCtx{
-		Grant:  q.Grant.WithContext(ctx),
-		Key:    q.Key.WithContext(ctx),
-		Server: q.Server.WithContext(ctx),
-		Token:  q.Token.WithContext(ctx),
-		User:   q.User.WithContext(ctx),
+		APIToken:        q.APIToken.WithContext(ctx),
+		AccessRequest:   q.AccessRequest.WithContext(ctx),
+		AuditEvent:      q.AuditEvent.WithContext(ctx),
+		Grant:           q.Grant.WithContext(ctx),
+		Group:           q.Group.WithContext(ctx),
+		GroupMember:     q.GroupMember.WithContext(ctx),
+		Key:             q.Key.WithContext(ctx),
+		MFAChallenge:    q.MFAChallenge.WithContext(ctx),
+		Policy:          q.Policy.WithContext(ctx),
+		RecoveryCode:    q.RecoveryCode.WithContext(ctx),
+		Server:          q.Server.WithContext(ctx),
+		ServerLabel:     q.ServerLabel.WithContext(ctx),
+		Session:         q.Session.WithContext(ctx),
+		Token:           q.Token.WithContext(ctx),
+		User:            q.User.WithContext(ctx),
+		Webhook:         q.Webhook.WithContext(ctx),
+		WebhookDelivery: q.WebhookDelivery.WithContext(ctx),
 	}
 }
 
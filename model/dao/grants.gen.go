@@ -29,9 +29,14 @@ func newGrant(db *gorm.DB, opts ...gen.DOOption) grant {
 	_grant.ALL = field.NewAsterisk(tableName)
 	_grant.ID = field.NewString(tableName, "id")
 	_grant.UserID = field.NewString(tableName, "user_id")
+	_grant.GroupID = field.NewString(tableName, "group_id")
 	_grant.ServerUser = field.NewString(tableName, "server_user")
 	_grant.ServerID = field.NewString(tableName, "server_id")
+	_grant.ServerSelector = field.NewString(tableName, "server_selector")
+	_grant.Effect = field.NewString(tableName, "effect")
 	_grant.CreatedAt = field.NewTime(tableName, "created_at")
+	_grant.NotBefore = field.NewTime(tableName, "not_before")
+	_grant.ExpiresAt = field.NewTime(tableName, "expires_at")
 	_grant.User = grantBelongsToUser{
 		db: db.Session(&gorm.Session{}),
 
@@ -85,13 +90,18 @@ func newGrant(db *gorm.DB, opts ...gen.DOOption) grant {
 type grant struct {
 	grantDo
 
-	ALL        field.Asterisk
-	ID         field.String
-	UserID     field.String
-	ServerUser field.String
-	ServerID   field.String
-	CreatedAt  field.Time
-	User       grantBelongsToUser
+	ALL            field.Asterisk
+	ID             field.String
+	UserID         field.String
+	GroupID        field.String
+	ServerUser     field.String
+	ServerID       field.String
+	ServerSelector field.String
+	Effect         field.String
+	CreatedAt      field.Time
+	NotBefore      field.Time
+	ExpiresAt      field.Time
+	User           grantBelongsToUser
 
 	fieldMap map[string]field.Expr
 }
@@ -110,9 +120,14 @@ func (g *grant) updateTableName(table string) *grant {
 	g.ALL = field.NewAsterisk(table)
 	g.ID = field.NewString(table, "id")
 	g.UserID = field.NewString(table, "user_id")
+	g.GroupID = field.NewString(table, "group_id")
 	g.ServerUser = field.NewString(table, "server_user")
 	g.ServerID = field.NewString(table, "server_id")
+	g.ServerSelector = field.NewString(table, "server_selector")
+	g.Effect = field.NewString(table, "effect")
 	g.CreatedAt = field.NewTime(table, "created_at")
+	g.NotBefore = field.NewTime(table, "not_before")
+	g.ExpiresAt = field.NewTime(table, "expires_at")
 
 	g.fillFieldMap()
 
@@ -129,12 +144,17 @@ func (g *grant) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (g *grant) fillFieldMap() {
-	g.fieldMap = make(map[string]field.Expr, 6)
+	g.fieldMap = make(map[string]field.Expr, 11)
 	g.fieldMap["id"] = g.ID
 	g.fieldMap["user_id"] = g.UserID
+	g.fieldMap["group_id"] = g.GroupID
 	g.fieldMap["server_user"] = g.ServerUser
 	g.fieldMap["server_id"] = g.ServerID
+	g.fieldMap["server_selector"] = g.ServerSelector
+	g.fieldMap["effect"] = g.Effect
 	g.fieldMap["created_at"] = g.CreatedAt
+	g.fieldMap["not_before"] = g.NotBefore
+	g.fieldMap["expires_at"] = g.ExpiresAt
 
 }
 
@@ -0,0 +1,335 @@
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/yankeguo/bunker/model"
+)
+
+func newPolicy(db *gorm.DB, opts ...gen.DOOption) policy {
+	_policy := policy{}
+
+	_policy.policyDo.UseDB(db, opts...)
+	_policy.policyDo.UseModel(&model.Policy{})
+
+	tableName := _policy.policyDo.TableName()
+	_policy.ALL = field.NewAsterisk(tableName)
+	_policy.ID = field.NewString(tableName, "id")
+	_policy.Description = field.NewString(tableName, "description")
+	_policy.Expression = field.NewString(tableName, "expression")
+	_policy.Mode = field.NewString(tableName, "mode")
+	_policy.CreatedAt = field.NewTime(tableName, "created_at")
+
+	_policy.fillFieldMap()
+
+	return _policy
+}
+
+type policy struct {
+	policyDo
+
+	ALL         field.Asterisk
+	ID          field.String
+	Description field.String
+	Expression  field.String
+	Mode        field.String
+	CreatedAt   field.Time
+
+	fieldMap map[string]field.Expr
+}
+
+func (p policy) Table(newTableName string) *policy {
+	p.policyDo.UseTable(newTableName)
+	return p.updateTableName(newTableName)
+}
+
+func (p policy) As(alias string) *policy {
+	p.policyDo.DO = *(p.policyDo.As(alias).(*gen.DO))
+	return p.updateTableName(alias)
+}
+
+func (p *policy) updateTableName(table string) *policy {
+	p.ALL = field.NewAsterisk(table)
+	p.ID = field.NewString(table, "id")
+	p.Description = field.NewString(table, "description")
+	p.Expression = field.NewString(table, "expression")
+	p.Mode = field.NewString(table, "mode")
+	p.CreatedAt = field.NewTime(table, "created_at")
+
+	p.fillFieldMap()
+
+	return p
+}
+
+func (p *policy) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := p.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (p *policy) fillFieldMap() {
+	p.fieldMap = make(map[string]field.Expr, 5)
+	p.fieldMap["id"] = p.ID
+	p.fieldMap["description"] = p.Description
+	p.fieldMap["expression"] = p.Expression
+	p.fieldMap["mode"] = p.Mode
+	p.fieldMap["created_at"] = p.CreatedAt
+}
+
+func (p policy) clone(db *gorm.DB) policy {
+	p.policyDo.ReplaceConnPool(db.Statement.ConnPool)
+	return p
+}
+
+func (p policy) replaceDB(db *gorm.DB) policy {
+	p.policyDo.ReplaceDB(db)
+	return p
+}
+
+type policyDo struct{ gen.DO }
+
+func (p policyDo) Debug() *policyDo {
+	return p.withDO(p.DO.Debug())
+}
+
+func (p policyDo) WithContext(ctx context.Context) *policyDo {
+	return p.withDO(p.DO.WithContext(ctx))
+}
+
+func (p policyDo) ReadDB() *policyDo {
+	return p.Clauses(dbresolver.Read)
+}
+
+func (p policyDo) WriteDB() *policyDo {
+	return p.Clauses(dbresolver.Write)
+}
+
+func (p policyDo) Session(config *gorm.Session) *policyDo {
+	return p.withDO(p.DO.Session(config))
+}
+
+func (p policyDo) Clauses(conds ...clause.Expression) *policyDo {
+	return p.withDO(p.DO.Clauses(conds...))
+}
+
+func (p policyDo) Returning(value interface{}, columns ...string) *policyDo {
+	return p.withDO(p.DO.Returning(value, columns...))
+}
+
+func (p policyDo) Not(conds ...gen.Condition) *policyDo {
+	return p.withDO(p.DO.Not(conds...))
+}
+
+func (p policyDo) Or(conds ...gen.Condition) *policyDo {
+	return p.withDO(p.DO.Or(conds...))
+}
+
+func (p policyDo) Select(conds ...field.Expr) *policyDo {
+	return p.withDO(p.DO.Select(conds...))
+}
+
+func (p policyDo) Where(conds ...gen.Condition) *policyDo {
+	return p.withDO(p.DO.Where(conds...))
+}
+
+func (p policyDo) Order(conds ...field.Expr) *policyDo {
+	return p.withDO(p.DO.Order(conds...))
+}
+
+func (p policyDo) Distinct(cols ...field.Expr) *policyDo {
+	return p.withDO(p.DO.Distinct(cols...))
+}
+
+func (p policyDo) Omit(cols ...field.Expr) *policyDo {
+	return p.withDO(p.DO.Omit(cols...))
+}
+
+func (p policyDo) Join(table schema.Tabler, on ...field.Expr) *policyDo {
+	return p.withDO(p.DO.Join(table, on...))
+}
+
+func (p policyDo) LeftJoin(table schema.Tabler, on ...field.Expr) *policyDo {
+	return p.withDO(p.DO.LeftJoin(table, on...))
+}
+
+func (p policyDo) RightJoin(table schema.Tabler, on ...field.Expr) *policyDo {
+	return p.withDO(p.DO.RightJoin(table, on...))
+}
+
+func (p policyDo) Group(cols ...field.Expr) *policyDo {
+	return p.withDO(p.DO.Group(cols...))
+}
+
+func (p policyDo) Having(conds ...gen.Condition) *policyDo {
+	return p.withDO(p.DO.Having(conds...))
+}
+
+func (p policyDo) Limit(limit int) *policyDo {
+	return p.withDO(p.DO.Limit(limit))
+}
+
+func (p policyDo) Offset(offset int) *policyDo {
+	return p.withDO(p.DO.Offset(offset))
+}
+
+func (p policyDo) Scopes(funcs ...func(gen.Dao) gen.Dao) *policyDo {
+	return p.withDO(p.DO.Scopes(funcs...))
+}
+
+func (p policyDo) Unscoped() *policyDo {
+	return p.withDO(p.DO.Unscoped())
+}
+
+func (p policyDo) Create(values ...*model.Policy) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return p.DO.Create(values)
+}
+
+func (p policyDo) CreateInBatches(values []*model.Policy, batchSize int) error {
+	return p.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (p policyDo) Save(values ...*model.Policy) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return p.DO.Save(values)
+}
+
+func (p policyDo) First() (*model.Policy, error) {
+	if result, err := p.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Policy), nil
+	}
+}
+
+func (p policyDo) Take() (*model.Policy, error) {
+	if result, err := p.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Policy), nil
+	}
+}
+
+func (p policyDo) Last() (*model.Policy, error) {
+	if result, err := p.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Policy), nil
+	}
+}
+
+func (p policyDo) Find() ([]*model.Policy, error) {
+	result, err := p.DO.Find()
+	return result.([]*model.Policy), err
+}
+
+func (p policyDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.Policy, err error) {
+	buf := make([]*model.Policy, 0, batchSize)
+	err = p.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (p policyDo) FindInBatches(result *[]*model.Policy, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return p.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (p policyDo) Attrs(attrs ...field.AssignExpr) *policyDo {
+	return p.withDO(p.DO.Attrs(attrs...))
+}
+
+func (p policyDo) Assign(attrs ...field.AssignExpr) *policyDo {
+	return p.withDO(p.DO.Assign(attrs...))
+}
+
+func (p policyDo) Joins(fields ...field.RelationField) *policyDo {
+	for _, _f := range fields {
+		p = *p.withDO(p.DO.Joins(_f))
+	}
+	return &p
+}
+
+func (p policyDo) Preload(fields ...field.RelationField) *policyDo {
+	for _, _f := range fields {
+		p = *p.withDO(p.DO.Preload(_f))
+	}
+	return &p
+}
+
+func (p policyDo) FirstOrInit() (*model.Policy, error) {
+	if result, err := p.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Policy), nil
+	}
+}
+
+func (p policyDo) FirstOrCreate() (*model.Policy, error) {
+	if result, err := p.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.Policy), nil
+	}
+}
+
+func (p policyDo) FindByPage(offset int, limit int) (result []*model.Policy, count int64, err error) {
+	result, err = p.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = p.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (p policyDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = p.Count()
+	if err != nil {
+		return
+	}
+
+	err = p.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (p policyDo) Scan(result interface{}) (err error) {
+	return p.DO.Scan(result)
+}
+
+func (p policyDo) Delete(models ...*model.Policy) (result gen.ResultInfo, err error) {
+	return p.DO.Delete(models)
+}
+
+func (p *policyDo) withDO(do gen.Dao) *policyDo {
+	p.DO = *do.(*gen.DO)
+	return p
+}
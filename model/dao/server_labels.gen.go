@@ -0,0 +1,327 @@
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/yankeguo/bunker/model"
+)
+
+func newServerLabel(db *gorm.DB, opts ...gen.DOOption) serverLabel {
+	_serverLabel := serverLabel{}
+
+	_serverLabel.serverLabelDo.UseDB(db, opts...)
+	_serverLabel.serverLabelDo.UseModel(&model.ServerLabel{})
+
+	tableName := _serverLabel.serverLabelDo.TableName()
+	_serverLabel.ALL = field.NewAsterisk(tableName)
+	_serverLabel.ServerID = field.NewString(tableName, "server_id")
+	_serverLabel.Key = field.NewString(tableName, "key")
+	_serverLabel.Value = field.NewString(tableName, "value")
+
+	_serverLabel.fillFieldMap()
+
+	return _serverLabel
+}
+
+type serverLabel struct {
+	serverLabelDo
+
+	ALL      field.Asterisk
+	ServerID field.String
+	Key      field.String
+	Value    field.String
+
+	fieldMap map[string]field.Expr
+}
+
+func (s serverLabel) Table(newTableName string) *serverLabel {
+	s.serverLabelDo.UseTable(newTableName)
+	return s.updateTableName(newTableName)
+}
+
+func (s serverLabel) As(alias string) *serverLabel {
+	s.serverLabelDo.DO = *(s.serverLabelDo.As(alias).(*gen.DO))
+	return s.updateTableName(alias)
+}
+
+func (s *serverLabel) updateTableName(table string) *serverLabel {
+	s.ALL = field.NewAsterisk(table)
+	s.ServerID = field.NewString(table, "server_id")
+	s.Key = field.NewString(table, "key")
+	s.Value = field.NewString(table, "value")
+
+	s.fillFieldMap()
+
+	return s
+}
+
+func (s *serverLabel) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := s.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (s *serverLabel) fillFieldMap() {
+	s.fieldMap = make(map[string]field.Expr, 3)
+	s.fieldMap["server_id"] = s.ServerID
+	s.fieldMap["key"] = s.Key
+	s.fieldMap["value"] = s.Value
+}
+
+func (s serverLabel) clone(db *gorm.DB) serverLabel {
+	s.serverLabelDo.ReplaceConnPool(db.Statement.ConnPool)
+	return s
+}
+
+func (s serverLabel) replaceDB(db *gorm.DB) serverLabel {
+	s.serverLabelDo.ReplaceDB(db)
+	return s
+}
+
+type serverLabelDo struct{ gen.DO }
+
+func (s serverLabelDo) Debug() *serverLabelDo {
+	return s.withDO(s.DO.Debug())
+}
+
+func (s serverLabelDo) WithContext(ctx context.Context) *serverLabelDo {
+	return s.withDO(s.DO.WithContext(ctx))
+}
+
+func (s serverLabelDo) ReadDB() *serverLabelDo {
+	return s.Clauses(dbresolver.Read)
+}
+
+func (s serverLabelDo) WriteDB() *serverLabelDo {
+	return s.Clauses(dbresolver.Write)
+}
+
+func (s serverLabelDo) Session(config *gorm.Session) *serverLabelDo {
+	return s.withDO(s.DO.Session(config))
+}
+
+func (s serverLabelDo) Clauses(conds ...clause.Expression) *serverLabelDo {
+	return s.withDO(s.DO.Clauses(conds...))
+}
+
+func (s serverLabelDo) Returning(value interface{}, columns ...string) *serverLabelDo {
+	return s.withDO(s.DO.Returning(value, columns...))
+}
+
+func (s serverLabelDo) Not(conds ...gen.Condition) *serverLabelDo {
+	return s.withDO(s.DO.Not(conds...))
+}
+
+func (s serverLabelDo) Or(conds ...gen.Condition) *serverLabelDo {
+	return s.withDO(s.DO.Or(conds...))
+}
+
+func (s serverLabelDo) Select(conds ...field.Expr) *serverLabelDo {
+	return s.withDO(s.DO.Select(conds...))
+}
+
+func (s serverLabelDo) Where(conds ...gen.Condition) *serverLabelDo {
+	return s.withDO(s.DO.Where(conds...))
+}
+
+func (s serverLabelDo) Order(conds ...field.Expr) *serverLabelDo {
+	return s.withDO(s.DO.Order(conds...))
+}
+
+func (s serverLabelDo) Distinct(cols ...field.Expr) *serverLabelDo {
+	return s.withDO(s.DO.Distinct(cols...))
+}
+
+func (s serverLabelDo) Omit(cols ...field.Expr) *serverLabelDo {
+	return s.withDO(s.DO.Omit(cols...))
+}
+
+func (s serverLabelDo) Join(table schema.Tabler, on ...field.Expr) *serverLabelDo {
+	return s.withDO(s.DO.Join(table, on...))
+}
+
+func (s serverLabelDo) LeftJoin(table schema.Tabler, on ...field.Expr) *serverLabelDo {
+	return s.withDO(s.DO.LeftJoin(table, on...))
+}
+
+func (s serverLabelDo) RightJoin(table schema.Tabler, on ...field.Expr) *serverLabelDo {
+	return s.withDO(s.DO.RightJoin(table, on...))
+}
+
+func (s serverLabelDo) Group(cols ...field.Expr) *serverLabelDo {
+	return s.withDO(s.DO.Group(cols...))
+}
+
+func (s serverLabelDo) Having(conds ...gen.Condition) *serverLabelDo {
+	return s.withDO(s.DO.Having(conds...))
+}
+
+func (s serverLabelDo) Limit(limit int) *serverLabelDo {
+	return s.withDO(s.DO.Limit(limit))
+}
+
+func (s serverLabelDo) Offset(offset int) *serverLabelDo {
+	return s.withDO(s.DO.Offset(offset))
+}
+
+func (s serverLabelDo) Scopes(funcs ...func(gen.Dao) gen.Dao) *serverLabelDo {
+	return s.withDO(s.DO.Scopes(funcs...))
+}
+
+func (s serverLabelDo) Unscoped() *serverLabelDo {
+	return s.withDO(s.DO.Unscoped())
+}
+
+func (s serverLabelDo) Create(values ...*model.ServerLabel) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return s.DO.Create(values)
+}
+
+func (s serverLabelDo) CreateInBatches(values []*model.ServerLabel, batchSize int) error {
+	return s.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (s serverLabelDo) Save(values ...*model.ServerLabel) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return s.DO.Save(values)
+}
+
+func (s serverLabelDo) First() (*model.ServerLabel, error) {
+	if result, err := s.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.ServerLabel), nil
+	}
+}
+
+func (s serverLabelDo) Take() (*model.ServerLabel, error) {
+	if result, err := s.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.ServerLabel), nil
+	}
+}
+
+func (s serverLabelDo) Last() (*model.ServerLabel, error) {
+	if result, err := s.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.ServerLabel), nil
+	}
+}
+
+func (s serverLabelDo) Find() ([]*model.ServerLabel, error) {
+	result, err := s.DO.Find()
+	return result.([]*model.ServerLabel), err
+}
+
+func (s serverLabelDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.ServerLabel, err error) {
+	buf := make([]*model.ServerLabel, 0, batchSize)
+	err = s.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (s serverLabelDo) FindInBatches(result *[]*model.ServerLabel, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return s.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (s serverLabelDo) Attrs(attrs ...field.AssignExpr) *serverLabelDo {
+	return s.withDO(s.DO.Attrs(attrs...))
+}
+
+func (s serverLabelDo) Assign(attrs ...field.AssignExpr) *serverLabelDo {
+	return s.withDO(s.DO.Assign(attrs...))
+}
+
+func (s serverLabelDo) Joins(fields ...field.RelationField) *serverLabelDo {
+	for _, _f := range fields {
+		s = *s.withDO(s.DO.Joins(_f))
+	}
+	return &s
+}
+
+func (s serverLabelDo) Preload(fields ...field.RelationField) *serverLabelDo {
+	for _, _f := range fields {
+		s = *s.withDO(s.DO.Preload(_f))
+	}
+	return &s
+}
+
+func (s serverLabelDo) FirstOrInit() (*model.ServerLabel, error) {
+	if result, err := s.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.ServerLabel), nil
+	}
+}
+
+func (s serverLabelDo) FirstOrCreate() (*model.ServerLabel, error) {
+	if result, err := s.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.ServerLabel), nil
+	}
+}
+
+func (s serverLabelDo) FindByPage(offset int, limit int) (result []*model.ServerLabel, count int64, err error) {
+	result, err = s.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = s.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (s serverLabelDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = s.Count()
+	if err != nil {
+		return
+	}
+
+	err = s.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (s serverLabelDo) Scan(result interface{}) (err error) {
+	return s.DO.Scan(result)
+}
+
+func (s serverLabelDo) Delete(models ...*model.ServerLabel) (result gen.ResultInfo, err error) {
+	return s.DO.Delete(models)
+}
+
+func (s *serverLabelDo) withDO(do gen.Dao) *serverLabelDo {
+	s.DO = *do.(*gen.DO)
+	return s
+}
@@ -30,6 +30,13 @@ func newServer(db *gorm.DB, opts ...gen.DOOption) server {
 	_server.ID = field.NewString(tableName, "id")
 	_server.Address = field.NewString(tableName, "address")
 	_server.CreatedAt = field.NewTime(tableName, "created_at")
+	_server.HostKey = field.NewString(tableName, "host_key")
+	_server.RequireMFA = field.NewBool(tableName, "require_mfa")
+	_server.Labels = serverHasManyLabels{
+		db: db.Session(&gorm.Session{}),
+
+		RelationField: field.NewRelation("Labels", "model.ServerLabel"),
+	}
 
 	_server.fillFieldMap()
 
@@ -39,10 +46,13 @@ func newServer(db *gorm.DB, opts ...gen.DOOption) server {
 type server struct {
 	serverDo
 
-	ALL       field.Asterisk
-	ID        field.String
-	Address   field.String
-	CreatedAt field.Time
+	ALL        field.Asterisk
+	ID         field.String
+	Address    field.String
+	CreatedAt  field.Time
+	HostKey    field.String
+	RequireMFA field.Bool
+	Labels     serverHasManyLabels
 
 	fieldMap map[string]field.Expr
 }
@@ -62,6 +72,8 @@ func (s *server) updateTableName(table string) *server {
 	s.ID = field.NewString(table, "id")
 	s.Address = field.NewString(table, "address")
 	s.CreatedAt = field.NewTime(table, "created_at")
+	s.HostKey = field.NewString(table, "host_key")
+	s.RequireMFA = field.NewBool(table, "require_mfa")
 
 	s.fillFieldMap()
 
@@ -78,10 +90,13 @@ func (s *server) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (s *server) fillFieldMap() {
-	s.fieldMap = make(map[string]field.Expr, 3)
+	s.fieldMap = make(map[string]field.Expr, 6)
 	s.fieldMap["id"] = s.ID
 	s.fieldMap["address"] = s.Address
 	s.fieldMap["created_at"] = s.CreatedAt
+	s.fieldMap["host_key"] = s.HostKey
+	s.fieldMap["require_mfa"] = s.RequireMFA
+
 }
 
 func (s server) clone(db *gorm.DB) server {
@@ -94,6 +109,77 @@ func (s server) replaceDB(db *gorm.DB) server {
 	return s
 }
 
+type serverHasManyLabels struct {
+	db *gorm.DB
+
+	field.RelationField
+}
+
+func (a serverHasManyLabels) Where(conds ...field.Expr) *serverHasManyLabels {
+	if len(conds) == 0 {
+		return &a
+	}
+
+	exprs := make([]clause.Expression, 0, len(conds))
+	for _, cond := range conds {
+		exprs = append(exprs, cond.BeCond().(clause.Expression))
+	}
+	a.db = a.db.Clauses(clause.Where{Exprs: exprs})
+	return &a
+}
+
+func (a serverHasManyLabels) WithContext(ctx context.Context) *serverHasManyLabels {
+	a.db = a.db.WithContext(ctx)
+	return &a
+}
+
+func (a serverHasManyLabels) Session(session *gorm.Session) *serverHasManyLabels {
+	a.db = a.db.Session(session)
+	return &a
+}
+
+func (a serverHasManyLabels) Model(m *model.Server) *serverHasManyLabelsTx {
+	return &serverHasManyLabelsTx{a.db.Model(m).Association(a.Name())}
+}
+
+type serverHasManyLabelsTx struct{ tx *gorm.Association }
+
+func (a serverHasManyLabelsTx) Find() (result []*model.ServerLabel, err error) {
+	return result, a.tx.Find(&result)
+}
+
+func (a serverHasManyLabelsTx) Append(values ...*model.ServerLabel) (err error) {
+	targetValues := make([]interface{}, len(values))
+	for i, v := range values {
+		targetValues[i] = v
+	}
+	return a.tx.Append(targetValues...)
+}
+
+func (a serverHasManyLabelsTx) Replace(values ...*model.ServerLabel) (err error) {
+	targetValues := make([]interface{}, len(values))
+	for i, v := range values {
+		targetValues[i] = v
+	}
+	return a.tx.Replace(targetValues...)
+}
+
+func (a serverHasManyLabelsTx) Delete(values ...*model.ServerLabel) (err error) {
+	targetValues := make([]interface{}, len(values))
+	for i, v := range values {
+		targetValues[i] = v
+	}
+	return a.tx.Delete(targetValues...)
+}
+
+func (a serverHasManyLabelsTx) Clear() error {
+	return a.tx.Clear()
+}
+
+func (a serverHasManyLabelsTx) Count() int64 {
+	return a.tx.Count()
+}
+
 type serverDo struct{ gen.DO }
 
 func (s serverDo) Debug() *serverDo {
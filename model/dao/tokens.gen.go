@@ -32,6 +32,8 @@ func newToken(db *gorm.DB, opts ...gen.DOOption) token {
 	_token.CreatedAt = field.NewTime(tableName, "created_at")
 	_token.VisitedAt = field.NewTime(tableName, "visited_at")
 	_token.UserAgent = field.NewString(tableName, "user_agent")
+	_token.IP = field.NewString(tableName, "ip")
+	_token.MFA = field.NewBool(tableName, "mfa")
 	_token.User = tokenBelongsToUser{
 		db: db.Session(&gorm.Session{}),
 
@@ -91,6 +93,8 @@ type token struct {
 	CreatedAt field.Time
 	VisitedAt field.Time
 	UserAgent field.String
+	IP        field.String
+	MFA       field.Bool
 	User      tokenBelongsToUser
 
 	fieldMap map[string]field.Expr
@@ -113,6 +117,8 @@ func (t *token) updateTableName(table string) *token {
 	t.CreatedAt = field.NewTime(table, "created_at")
 	t.VisitedAt = field.NewTime(table, "visited_at")
 	t.UserAgent = field.NewString(table, "user_agent")
+	t.IP = field.NewString(table, "ip")
+	t.MFA = field.NewBool(table, "mfa")
 
 	t.fillFieldMap()
 
@@ -129,12 +135,14 @@ func (t *token) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (t *token) fillFieldMap() {
-	t.fieldMap = make(map[string]field.Expr, 6)
+	t.fieldMap = make(map[string]field.Expr, 8)
 	t.fieldMap["id"] = t.ID
 	t.fieldMap["user_id"] = t.UserID
 	t.fieldMap["created_at"] = t.CreatedAt
 	t.fieldMap["visited_at"] = t.VisitedAt
 	t.fieldMap["user_agent"] = t.UserAgent
+	t.fieldMap["ip"] = t.IP
+	t.fieldMap["mfa"] = t.MFA
 
 }
 
@@ -33,6 +33,13 @@ func newUser(db *gorm.DB, opts ...gen.DOOption) user {
 	_user.VisitedAt = field.NewTime(tableName, "visited_at")
 	_user.IsAdmin = field.NewBool(tableName, "is_admin")
 	_user.IsBlocked = field.NewBool(tableName, "is_blocked")
+	_user.BlockedBy = field.NewString(tableName, "blocked_by")
+	_user.Source = field.NewString(tableName, "source")
+	_user.TOTPSecret = field.NewString(tableName, "totp_secret")
+	_user.TOTPEnabled = field.NewBool(tableName, "totp_enabled")
+	_user.TOTPLastStep = field.NewInt64(tableName, "totp_last_step")
+	_user.MFAFailures = field.NewInt(tableName, "mfa_failures")
+	_user.MFALockedUntil = field.NewTime(tableName, "mfa_locked_until")
 	_user.Keys = userHasManyKeys{
 		db: db.Session(&gorm.Session{}),
 
@@ -117,6 +124,13 @@ type user struct {
 	VisitedAt      field.Time
 	IsAdmin        field.Bool
 	IsBlocked      field.Bool
+	BlockedBy      field.String
+	Source         field.String
+	TOTPSecret     field.String
+	TOTPEnabled    field.Bool
+	TOTPLastStep   field.Int64
+	MFAFailures    field.Int
+	MFALockedUntil field.Time
 	Keys           userHasManyKeys
 
 	Grants userHasManyGrants
@@ -144,6 +158,13 @@ func (u *user) updateTableName(table string) *user {
 	u.VisitedAt = field.NewTime(table, "visited_at")
 	u.IsAdmin = field.NewBool(table, "is_admin")
 	u.IsBlocked = field.NewBool(table, "is_blocked")
+	u.BlockedBy = field.NewString(table, "blocked_by")
+	u.Source = field.NewString(table, "source")
+	u.TOTPSecret = field.NewString(table, "totp_secret")
+	u.TOTPEnabled = field.NewBool(table, "totp_enabled")
+	u.TOTPLastStep = field.NewInt64(table, "totp_last_step")
+	u.MFAFailures = field.NewInt(table, "mfa_failures")
+	u.MFALockedUntil = field.NewTime(table, "mfa_locked_until")
 
 	u.fillFieldMap()
 
@@ -160,13 +181,20 @@ func (u *user) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (u *user) fillFieldMap() {
-	u.fieldMap = make(map[string]field.Expr, 9)
+	u.fieldMap = make(map[string]field.Expr, 16)
 	u.fieldMap["id"] = u.ID
 	u.fieldMap["password_digest"] = u.PasswordDigest
 	u.fieldMap["created_at"] = u.CreatedAt
 	u.fieldMap["visited_at"] = u.VisitedAt
 	u.fieldMap["is_admin"] = u.IsAdmin
 	u.fieldMap["is_blocked"] = u.IsBlocked
+	u.fieldMap["blocked_by"] = u.BlockedBy
+	u.fieldMap["source"] = u.Source
+	u.fieldMap["totp_secret"] = u.TOTPSecret
+	u.fieldMap["totp_enabled"] = u.TOTPEnabled
+	u.fieldMap["totp_last_step"] = u.TOTPLastStep
+	u.fieldMap["mfa_failures"] = u.MFAFailures
+	u.fieldMap["mfa_locked_until"] = u.MFALockedUntil
 
 }
 
@@ -0,0 +1,363 @@
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+// Code generated by gorm.io/gen. DO NOT EDIT.
+
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/yankeguo/bunker/model"
+)
+
+func newWebhookDelivery(db *gorm.DB, opts ...gen.DOOption) webhookDelivery {
+	_webhookDelivery := webhookDelivery{}
+
+	_webhookDelivery.webhookDeliveryDo.UseDB(db, opts...)
+	_webhookDelivery.webhookDeliveryDo.UseModel(&model.WebhookDelivery{})
+
+	tableName := _webhookDelivery.webhookDeliveryDo.TableName()
+	_webhookDelivery.ALL = field.NewAsterisk(tableName)
+	_webhookDelivery.ID = field.NewInt64(tableName, "id")
+	_webhookDelivery.WebhookID = field.NewString(tableName, "webhook_id")
+	_webhookDelivery.AuditEventID = field.NewInt64(tableName, "audit_event_id")
+	_webhookDelivery.Action = field.NewString(tableName, "action")
+	_webhookDelivery.Payload = field.NewString(tableName, "payload")
+	_webhookDelivery.Status = field.NewString(tableName, "status")
+	_webhookDelivery.Attempts = field.NewInt(tableName, "attempts")
+	_webhookDelivery.NextAttemptAt = field.NewTime(tableName, "next_attempt_at")
+	_webhookDelivery.ResponseCode = field.NewInt(tableName, "response_code")
+	_webhookDelivery.LastError = field.NewString(tableName, "last_error")
+	_webhookDelivery.CreatedAt = field.NewTime(tableName, "created_at")
+	_webhookDelivery.DeliveredAt = field.NewTime(tableName, "delivered_at")
+
+	_webhookDelivery.fillFieldMap()
+
+	return _webhookDelivery
+}
+
+type webhookDelivery struct {
+	webhookDeliveryDo
+
+	ALL           field.Asterisk
+	ID            field.Int64
+	WebhookID     field.String
+	AuditEventID  field.Int64
+	Action        field.String
+	Payload       field.String
+	Status        field.String
+	Attempts      field.Int
+	NextAttemptAt field.Time
+	ResponseCode  field.Int
+	LastError     field.String
+	CreatedAt     field.Time
+	DeliveredAt   field.Time
+
+	fieldMap map[string]field.Expr
+}
+
+func (w webhookDelivery) Table(newTableName string) *webhookDelivery {
+	w.webhookDeliveryDo.UseTable(newTableName)
+	return w.updateTableName(newTableName)
+}
+
+func (w webhookDelivery) As(alias string) *webhookDelivery {
+	w.webhookDeliveryDo.DO = *(w.webhookDeliveryDo.As(alias).(*gen.DO))
+	return w.updateTableName(alias)
+}
+
+func (w *webhookDelivery) updateTableName(table string) *webhookDelivery {
+	w.ALL = field.NewAsterisk(table)
+	w.ID = field.NewInt64(table, "id")
+	w.WebhookID = field.NewString(table, "webhook_id")
+	w.AuditEventID = field.NewInt64(table, "audit_event_id")
+	w.Action = field.NewString(table, "action")
+	w.Payload = field.NewString(table, "payload")
+	w.Status = field.NewString(table, "status")
+	w.Attempts = field.NewInt(table, "attempts")
+	w.NextAttemptAt = field.NewTime(table, "next_attempt_at")
+	w.ResponseCode = field.NewInt(table, "response_code")
+	w.LastError = field.NewString(table, "last_error")
+	w.CreatedAt = field.NewTime(table, "created_at")
+	w.DeliveredAt = field.NewTime(table, "delivered_at")
+
+	w.fillFieldMap()
+
+	return w
+}
+
+func (w *webhookDelivery) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := w.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (w *webhookDelivery) fillFieldMap() {
+	w.fieldMap = make(map[string]field.Expr, 12)
+	w.fieldMap["id"] = w.ID
+	w.fieldMap["webhook_id"] = w.WebhookID
+	w.fieldMap["audit_event_id"] = w.AuditEventID
+	w.fieldMap["action"] = w.Action
+	w.fieldMap["payload"] = w.Payload
+	w.fieldMap["status"] = w.Status
+	w.fieldMap["attempts"] = w.Attempts
+	w.fieldMap["next_attempt_at"] = w.NextAttemptAt
+	w.fieldMap["response_code"] = w.ResponseCode
+	w.fieldMap["last_error"] = w.LastError
+	w.fieldMap["created_at"] = w.CreatedAt
+	w.fieldMap["delivered_at"] = w.DeliveredAt
+}
+
+func (w webhookDelivery) clone(db *gorm.DB) webhookDelivery {
+	w.webhookDeliveryDo.ReplaceConnPool(db.Statement.ConnPool)
+	return w
+}
+
+func (w webhookDelivery) replaceDB(db *gorm.DB) webhookDelivery {
+	w.webhookDeliveryDo.ReplaceDB(db)
+	return w
+}
+
+type webhookDeliveryDo struct{ gen.DO }
+
+func (w webhookDeliveryDo) Debug() *webhookDeliveryDo {
+	return w.withDO(w.DO.Debug())
+}
+
+func (w webhookDeliveryDo) WithContext(ctx context.Context) *webhookDeliveryDo {
+	return w.withDO(w.DO.WithContext(ctx))
+}
+
+func (w webhookDeliveryDo) ReadDB() *webhookDeliveryDo {
+	return w.Clauses(dbresolver.Read)
+}
+
+func (w webhookDeliveryDo) WriteDB() *webhookDeliveryDo {
+	return w.Clauses(dbresolver.Write)
+}
+
+func (w webhookDeliveryDo) Session(config *gorm.Session) *webhookDeliveryDo {
+	return w.withDO(w.DO.Session(config))
+}
+
+func (w webhookDeliveryDo) Clauses(conds ...clause.Expression) *webhookDeliveryDo {
+	return w.withDO(w.DO.Clauses(conds...))
+}
+
+func (w webhookDeliveryDo) Returning(value interface{}, columns ...string) *webhookDeliveryDo {
+	return w.withDO(w.DO.Returning(value, columns...))
+}
+
+func (w webhookDeliveryDo) Not(conds ...gen.Condition) *webhookDeliveryDo {
+	return w.withDO(w.DO.Not(conds...))
+}
+
+func (w webhookDeliveryDo) Or(conds ...gen.Condition) *webhookDeliveryDo {
+	return w.withDO(w.DO.Or(conds...))
+}
+
+func (w webhookDeliveryDo) Select(conds ...field.Expr) *webhookDeliveryDo {
+	return w.withDO(w.DO.Select(conds...))
+}
+
+func (w webhookDeliveryDo) Where(conds ...gen.Condition) *webhookDeliveryDo {
+	return w.withDO(w.DO.Where(conds...))
+}
+
+func (w webhookDeliveryDo) Order(conds ...field.Expr) *webhookDeliveryDo {
+	return w.withDO(w.DO.Order(conds...))
+}
+
+func (w webhookDeliveryDo) Distinct(cols ...field.Expr) *webhookDeliveryDo {
+	return w.withDO(w.DO.Distinct(cols...))
+}
+
+func (w webhookDeliveryDo) Omit(cols ...field.Expr) *webhookDeliveryDo {
+	return w.withDO(w.DO.Omit(cols...))
+}
+
+func (w webhookDeliveryDo) Join(table schema.Tabler, on ...field.Expr) *webhookDeliveryDo {
+	return w.withDO(w.DO.Join(table, on...))
+}
+
+func (w webhookDeliveryDo) LeftJoin(table schema.Tabler, on ...field.Expr) *webhookDeliveryDo {
+	return w.withDO(w.DO.LeftJoin(table, on...))
+}
+
+func (w webhookDeliveryDo) RightJoin(table schema.Tabler, on ...field.Expr) *webhookDeliveryDo {
+	return w.withDO(w.DO.RightJoin(table, on...))
+}
+
+func (w webhookDeliveryDo) Group(cols ...field.Expr) *webhookDeliveryDo {
+	return w.withDO(w.DO.Group(cols...))
+}
+
+func (w webhookDeliveryDo) Having(conds ...gen.Condition) *webhookDeliveryDo {
+	return w.withDO(w.DO.Having(conds...))
+}
+
+func (w webhookDeliveryDo) Limit(limit int) *webhookDeliveryDo {
+	return w.withDO(w.DO.Limit(limit))
+}
+
+func (w webhookDeliveryDo) Offset(offset int) *webhookDeliveryDo {
+	return w.withDO(w.DO.Offset(offset))
+}
+
+func (w webhookDeliveryDo) Scopes(funcs ...func(gen.Dao) gen.Dao) *webhookDeliveryDo {
+	return w.withDO(w.DO.Scopes(funcs...))
+}
+
+func (w webhookDeliveryDo) Unscoped() *webhookDeliveryDo {
+	return w.withDO(w.DO.Unscoped())
+}
+
+func (w webhookDeliveryDo) Create(values ...*model.WebhookDelivery) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return w.DO.Create(values)
+}
+
+func (w webhookDeliveryDo) CreateInBatches(values []*model.WebhookDelivery, batchSize int) error {
+	return w.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (w webhookDeliveryDo) Save(values ...*model.WebhookDelivery) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return w.DO.Save(values)
+}
+
+func (w webhookDeliveryDo) First() (*model.WebhookDelivery, error) {
+	if result, err := w.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.WebhookDelivery), nil
+	}
+}
+
+func (w webhookDeliveryDo) Take() (*model.WebhookDelivery, error) {
+	if result, err := w.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.WebhookDelivery), nil
+	}
+}
+
+func (w webhookDeliveryDo) Last() (*model.WebhookDelivery, error) {
+	if result, err := w.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.WebhookDelivery), nil
+	}
+}
+
+func (w webhookDeliveryDo) Find() ([]*model.WebhookDelivery, error) {
+	result, err := w.DO.Find()
+	return result.([]*model.WebhookDelivery), err
+}
+
+func (w webhookDeliveryDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*model.WebhookDelivery, err error) {
+	buf := make([]*model.WebhookDelivery, 0, batchSize)
+	err = w.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (w webhookDeliveryDo) FindInBatches(result *[]*model.WebhookDelivery, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return w.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (w webhookDeliveryDo) Attrs(attrs ...field.AssignExpr) *webhookDeliveryDo {
+	return w.withDO(w.DO.Attrs(attrs...))
+}
+
+func (w webhookDeliveryDo) Assign(attrs ...field.AssignExpr) *webhookDeliveryDo {
+	return w.withDO(w.DO.Assign(attrs...))
+}
+
+func (w webhookDeliveryDo) Joins(fields ...field.RelationField) *webhookDeliveryDo {
+	for _, _f := range fields {
+		w = *w.withDO(w.DO.Joins(_f))
+	}
+	return &w
+}
+
+func (w webhookDeliveryDo) Preload(fields ...field.RelationField) *webhookDeliveryDo {
+	for _, _f := range fields {
+		w = *w.withDO(w.DO.Preload(_f))
+	}
+	return &w
+}
+
+func (w webhookDeliveryDo) FirstOrInit() (*model.WebhookDelivery, error) {
+	if result, err := w.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.WebhookDelivery), nil
+	}
+}
+
+func (w webhookDeliveryDo) FirstOrCreate() (*model.WebhookDelivery, error) {
+	if result, err := w.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*model.WebhookDelivery), nil
+	}
+}
+
+func (w webhookDeliveryDo) FindByPage(offset int, limit int) (result []*model.WebhookDelivery, count int64, err error) {
+	result, err = w.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = w.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (w webhookDeliveryDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = w.Count()
+	if err != nil {
+		return
+	}
+
+	err = w.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (w webhookDeliveryDo) Scan(result interface{}) (err error) {
+	return w.DO.Scan(result)
+}
+
+func (w webhookDeliveryDo) Delete(models ...*model.WebhookDelivery) (result gen.ResultInfo, err error) {
+	return w.DO.Delete(models)
+}
+
+func (w *webhookDeliveryDo) withDO(do gen.Dao) *webhookDeliveryDo {
+	w.DO = *do.(*gen.DO)
+	return w
+}
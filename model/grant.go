@@ -2,12 +2,37 @@ package model
 
 import "time"
 
+const (
+	GrantEffectAllow = "allow"
+	GrantEffectDeny  = "deny"
+)
+
 type Grant struct {
-	ID         string    `gorm:"column:id;primaryKey" json:"id"`
-	UserID     string    `gorm:"column:user_id;index" json:"user_id"`
-	ServerUser string    `gorm:"column:server_user;index" json:"server_user"`
-	ServerID   string    `gorm:"column:server_id;index" json:"server_id"`
-	CreatedAt  time.Time `gorm:"column:created_at;index" json:"created_at"`
+	ID string `gorm:"column:id;primaryKey" json:"id"`
+	// grant targets either a user or a group
+	UserID     string `gorm:"column:user_id;index" json:"user_id"`
+	GroupID    string `gorm:"column:group_id;not null;default:'';index" json:"group_id"`
+	ServerUser string `gorm:"column:server_user;index" json:"server_user"`
+	ServerID   string `gorm:"column:server_id;index" json:"server_id"`
+	// label selector of servers, in addition to server_id, e.g. "env=prod,team in (payments,billing)"
+	ServerSelector string `gorm:"column:server_selector;not null;default:''" json:"server_selector"`
+	// see GrantEffect constants, deny overrides allow
+	Effect    string    `gorm:"column:effect;not null;default:'allow';index" json:"effect"`
+	CreatedAt time.Time `gorm:"column:created_at;index" json:"created_at"`
+	// grant is only effective within the time range if set
+	NotBefore *time.Time `gorm:"column:not_before" json:"not_before"`
+	ExpiresAt *time.Time `gorm:"column:expires_at;index" json:"expires_at"`
 
 	User User
 }
+
+// IsActive checks if the grant is effective at given time
+func (g Grant) IsActive(t time.Time) bool {
+	if g.NotBefore != nil && t.Before(*g.NotBefore) {
+		return false
+	}
+	if g.ExpiresAt != nil && !t.Before(*g.ExpiresAt) {
+		return false
+	}
+	return true
+}
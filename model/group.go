@@ -0,0 +1,23 @@
+package model
+
+import (
+	"regexp"
+	"time"
+)
+
+var GroupIDPattern = regexp.MustCompile(`^[a-z][a-z0-9\._\-]+$`)
+
+// Group is a set of users, grants of a group apply to all members
+type Group struct {
+	ID          string    `gorm:"column:id;primaryKey" json:"id"`
+	Description string    `gorm:"column:description;not null;default:''" json:"description"`
+	CreatedAt   time.Time `gorm:"column:created_at;not null;index" json:"created_at"`
+
+	Members []GroupMember `json:"members,omitempty"`
+}
+
+type GroupMember struct {
+	GroupID   string    `gorm:"column:group_id;primaryKey" json:"group_id"`
+	UserID    string    `gorm:"column:user_id;primaryKey;index" json:"user_id"`
+	CreatedAt time.Time `gorm:"column:created_at;not null" json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// MFAChallenge is a pending sign-in waiting for the second factor
+type MFAChallenge struct {
+	ID        string `gorm:"column:id;primarykey" json:"id"`
+	UserID    string `gorm:"column:user_id;not null;index" json:"user_id"`
+	UserAgent string `gorm:"column:user_agent;not null" json:"user_agent"`
+	// sign in method completed by the challenge, e.g. password, ldap or oidc
+	Method    string    `gorm:"column:method;not null;default:''" json:"method"`
+	Attempts  int       `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;index" json:"created_at"`
+}
+
+// RecoveryCode is a one-time code to pass the second factor without TOTP
+type RecoveryCode struct {
+	// sha256 of recovery code
+	ID        string     `gorm:"column:id;primarykey" json:"-"`
+	UserID    string     `gorm:"column:user_id;not null;index" json:"user_id"`
+	CreatedAt time.Time  `gorm:"column:created_at;not null" json:"created_at"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"regexp"
+	"time"
+)
+
+var PolicyIDPattern = regexp.MustCompile(`^[a-z][a-z0-9\._\-]+$`)
+
+const (
+	PolicyModeEnforce  = "enforce"
+	PolicyModeDryRun   = "dry_run"
+	PolicyModeDisabled = "disabled"
+)
+
+// Policy denies access allowed by grants, if the CEL expression evaluates to true
+type Policy struct {
+	ID          string `gorm:"column:id;primaryKey" json:"id"`
+	Description string `gorm:"column:description;not null;default:''" json:"description"`
+	Expression  string `gorm:"column:expression;not null" json:"expression"`
+	// see PolicyMode constants, decisions of dry run policies are logged but not enforced
+	Mode      string    `gorm:"column:mode;not null;index" json:"mode"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;index" json:"created_at"`
+}
@@ -1,9 +1,40 @@
 package model
 
-import "time"
+import (
+	"regexp"
+	"time"
+)
+
+var (
+	// LabelKeyPattern allows keys like "env" or "example.com/team"
+	LabelKeyPattern   = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\._/\-]{0,61}[a-zA-Z0-9])?$`)
+	LabelValuePattern = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9\._\-]{0,61}[a-zA-Z0-9])?)?$`)
+)
 
 type Server struct {
 	ID        string    `gorm:"column:id;primaryKey" json:"id"`
 	Address   string    `gorm:"column:address" json:"address"`
 	CreatedAt time.Time `gorm:"column:created_at;index" json:"created_at"`
+	// pinned host key in authorized_keys format, empty if not pinned yet
+	HostKey string `gorm:"column:host_key;not null;default:''" json:"host_key"`
+	// require users to pass a second factor before connecting
+	RequireMFA bool `gorm:"column:require_mfa;not null;default:0" json:"require_mfa"`
+
+	Labels []ServerLabel `json:"labels,omitempty"`
+}
+
+// LabelMap returns labels as a map, labels must be preloaded
+func (s Server) LabelMap() map[string]string {
+	m := map[string]string{}
+	for _, label := range s.Labels {
+		m[label.Key] = label.Value
+	}
+	return m
+}
+
+// ServerLabel is a key/value label of server, e.g. env=prod, selected by grants
+type ServerLabel struct {
+	ServerID string `gorm:"column:server_id;primaryKey" json:"server_id"`
+	Key      string `gorm:"column:key;primaryKey" json:"key"`
+	Value    string `gorm:"column:value;not null;default:'';index" json:"value"`
 }
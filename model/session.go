@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+type Session struct {
+	// hex encoded ssh session id
+	ID          string     `gorm:"column:id;primaryKey" json:"id"`
+	UserID      string     `gorm:"column:user_id;index" json:"user_id"`
+	KeyID       string     `gorm:"column:key_id;index" json:"key_id"`
+	ServerID    string     `gorm:"column:server_id;index" json:"server_id"`
+	ServerUser  string     `gorm:"column:server_user;index" json:"server_user"`
+	RemoteAddr  string     `gorm:"column:remote_addr" json:"remote_addr"`
+	StartedAt   time.Time  `gorm:"column:started_at;index" json:"started_at"`
+	EndedAt     *time.Time `gorm:"column:ended_at;index" json:"ended_at"`
+	BytesIn     int64      `gorm:"column:bytes_in;not null;default:0" json:"bytes_in"`
+	BytesOut    int64      `gorm:"column:bytes_out;not null;default:0" json:"bytes_out"`
+	ExitStatus  *int       `gorm:"column:exit_status" json:"exit_status"`
+	CloseReason string     `gorm:"column:close_reason" json:"close_reason"`
+}
@@ -8,6 +8,10 @@ type Token struct {
 	CreatedAt time.Time `gorm:"column:created_at;not null;index" json:"created_at"`
 	VisitedAt time.Time `gorm:"column:visited_at;not null;index" json:"visited_at"`
 	UserAgent string    `gorm:"column:user_agent;not null" json:"user_agent"`
+	// ip address of last visit
+	IP string `gorm:"column:ip;not null;default:''" json:"ip"`
+	// signed in with second factor
+	MFA bool `gorm:"column:mfa;not null;default:0" json:"mfa"`
 
 	User User `json:"-"`
 }
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TOTPPeriod = 30
+	TOTPDigits = 6
+	// accepted clock skew in periods
+	TOTPSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret generates a random base32 encoded TOTP secret
+func GenerateTOTPSecret() string {
+	buf := make([]byte, 20)
+	rand.Read(buf)
+	return totpEncoding.EncodeToString(buf)
+}
+
+// TOTPURI builds the otpauth URI for authenticator apps
+func TOTPURI(issuer string, account string, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(TOTPDigits))
+	q.Set("period", fmt.Sprint(TOTPPeriod))
+
+	return (&url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}).String()
+}
+
+// TOTPStep returns the time step of given time
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / TOTPPeriod
+}
+
+// TOTPCode computes the RFC 6238 code of given time step
+func TOTPCode(secret string, step int64) (code string, err error) {
+	var key []byte
+	if key, err = totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "="))); err != nil {
+		return
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+
+	h := hmac.New(sha1.New, key)
+	h.Write(msg)
+	sum := h.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	code = fmt.Sprintf("%06d", value%1000000)
+	return
+}
+
+// MatchTOTP finds the time step matching the code around given time, returns false if not matched
+func MatchTOTP(secret string, code string, t time.Time) (step int64, ok bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return
+	}
+
+	now := TOTPStep(t)
+
+	for i := now - TOTPSkew; i <= now+TOTPSkew; i++ {
+		expected, err := TOTPCode(secret, i)
+		if err != nil {
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return i, true
+		}
+	}
+	return
+}
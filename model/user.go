@@ -10,6 +10,12 @@ import (
 // UserIDPattern general name pattern
 var UserIDPattern = regexp.MustCompile(`^[a-z][a-z0-9\._\-]{3,}$`)
 
+const (
+	UserSourceLocal = "local"
+	UserSourceLDAP  = "ldap"
+	UserSourceOIDC  = "oidc"
+)
+
 type User struct {
 	ID             string    `gorm:"column:id;primarykey" json:"id"`
 	PasswordDigest string    `gorm:"column:password_digest;not null" json:"-"`
@@ -17,6 +23,17 @@ type User struct {
 	VisitedAt      time.Time `gorm:"column:visited_at;not null;index" json:"visited_at"`
 	IsAdmin        bool      `gorm:"column:is_admin;not null;default:0;index" json:"is_admin"`
 	IsBlocked      bool      `gorm:"column:is_blocked;not null;default:0;index" json:"is_blocked"`
+	// source blocked the user automatically, e.g. ldap, empty if blocked by admin
+	BlockedBy string `gorm:"column:blocked_by;not null;default:''" json:"blocked_by"`
+	// where the user is managed, see UserSource constants
+	Source string `gorm:"column:source;not null;default:'local';index" json:"source"`
+	// TOTP secret is kept while enrolling, and only used once enabled
+	TOTPSecret   string `gorm:"column:totp_secret;not null;default:''" json:"-"`
+	TOTPEnabled  bool   `gorm:"column:totp_enabled;not null;default:0" json:"totp_enabled"`
+	TOTPLastStep int64  `gorm:"column:totp_last_step;not null;default:0" json:"-"`
+	// failed second factor attempts, shared by web and ssh sign in
+	MFAFailures    int        `gorm:"column:mfa_failures;not null;default:0" json:"-"`
+	MFALockedUntil *time.Time `gorm:"column:mfa_locked_until" json:"mfa_locked_until"`
 
 	Keys   []Key   `json:"keys,omitempty"`
 	Grants []Grant `json:"grants,omitempty"`
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+const (
+	WebhookDeliveryPending = "pending"
+	WebhookDeliverySuccess = "success"
+	WebhookDeliveryFailed  = "failed"
+)
+
+// Webhook subscribes an url to audit events
+type Webhook struct {
+	ID  string `gorm:"column:id;primaryKey" json:"id"`
+	URL string `gorm:"column:url;not null" json:"url"`
+	// comma separated wildcard patterns of actions, optionally suffixed with ":result", e.g. "ssh.auth.*:failure"
+	Events string `gorm:"column:events;not null" json:"events"`
+	// key of HMAC-SHA256 signature
+	Secret    string    `gorm:"column:secret;not null" json:"-"`
+	Enabled   bool      `gorm:"column:enabled;not null;default:1" json:"enabled"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;index" json:"created_at"`
+}
+
+// WebhookDelivery is a queued or finished delivery of an audit event to a webhook
+type WebhookDelivery struct {
+	ID           int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	WebhookID    string `gorm:"column:webhook_id;not null;index" json:"webhook_id"`
+	AuditEventID int64  `gorm:"column:audit_event_id;not null;index" json:"audit_event_id"`
+	Action       string `gorm:"column:action;not null" json:"action"`
+	Payload      string `gorm:"column:payload;not null" json:"payload"`
+	// see WebhookDelivery constants
+	Status        string     `gorm:"column:status;not null;index" json:"status"`
+	Attempts      int        `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"column:next_attempt_at;not null;index" json:"next_attempt_at"`
+	ResponseCode  int        `gorm:"column:response_code;not null;default:0" json:"response_code"`
+	LastError     string     `gorm:"column:last_error;not null;default:''" json:"last_error"`
+	CreatedAt     time.Time  `gorm:"column:created_at;not null;index" json:"created_at"`
+	DeliveredAt   *time.Time `gorm:"column:delivered_at" json:"delivered_at"`
+}
@@ -0,0 +1,338 @@
+package bunker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = time.Minute * 10
+)
+
+type oidcOptions struct {
+	Enabled      bool     `json:"enabled"`
+	Issuer       string   `json:"issuer" validate:"required_if=Enabled true"`
+	ClientID     string   `json:"client_id" validate:"required_if=Enabled true"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url" validate:"required_if=Enabled true"`
+	Scopes       []string `json:"scopes" default:"[\"openid\",\"profile\",\"email\"]"`
+	// claim used as user id
+	UsernameClaim string `json:"username_claim" default:"preferred_username"`
+	GroupsClaim   string `json:"groups_claim" default:"groups"`
+	// members of these groups are admins, admin status is not managed if empty
+	AdminGroups []string `json:"admin_groups"`
+	// create users on first sign in
+	AutoProvision bool `json:"auto_provision"`
+	// allow signing in as existing users of other sources with the same id
+	LinkUsers bool `json:"link_users"`
+}
+
+// oidcClient discovers the provider lazily, so that bunker starts even if the provider is unavailable
+type oidcClient struct {
+	opts oidcOptions
+
+	mu       sync.Mutex
+	config   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func (o *oidcClient) get(ctx context.Context) (config *oauth2.Config, verifier *oidc.IDTokenVerifier, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.config == nil {
+		var provider *oidc.Provider
+		if provider, err = oidc.NewProvider(ctx, o.opts.Issuer); err != nil {
+			return
+		}
+		o.config = &oauth2.Config{
+			ClientID:     o.opts.ClientID,
+			ClientSecret: o.opts.ClientSecret,
+			RedirectURL:  o.opts.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       o.opts.Scopes,
+		}
+		o.verifier = provider.Verifier(&oidc.Config{ClientID: o.opts.ClientID})
+	}
+
+	return o.config, o.verifier, nil
+}
+
+// oidcIdentity is the user identity extracted from claims of id token
+type oidcIdentity struct {
+	Username string
+	Groups   []string
+	MFA      bool
+}
+
+func (o *oidcClient) identity(claims map[string]any) (ident oidcIdentity, err error) {
+	ident.Username, _ = claims[o.opts.UsernameClaim].(string)
+	ident.Username = strings.ToLower(strings.TrimSpace(ident.Username))
+
+	if !model.UserIDPattern.MatchString(ident.Username) {
+		err = errors.New("invalid username from identity provider: " + ident.Username)
+		return
+	}
+
+	switch groups := claims[o.opts.GroupsClaim].(type) {
+	case []any:
+		for _, group := range groups {
+			if s, ok := group.(string); ok {
+				ident.Groups = append(ident.Groups, s)
+			}
+		}
+	case string:
+		ident.Groups = []string{groups}
+	}
+
+	// authentication methods references, RFC 8176
+	if amr, ok := claims["amr"].([]any); ok {
+		for _, method := range amr {
+			if method == "mfa" || method == "otp" {
+				ident.MFA = true
+			}
+		}
+	}
+	return
+}
+
+func (o *oidcClient) isAdmin(groups []string) bool {
+	for _, group := range groups {
+		if slices.Contains(o.opts.AdminGroups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveOIDCSignIn redirects to the identity provider with authorization code flow and PKCE
+func (a *App) serveOIDCSignIn(rw http.ResponseWriter, req *http.Request) {
+	if !a.oidc.opts.Enabled {
+		http.NotFound(rw, req)
+		return
+	}
+
+	config, _, err := a.oidc.get(req.Context())
+	if err != nil {
+		a.log.With("error", err).Error("oidc discovery")
+		http.Error(rw, "identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	var (
+		state    = randomHex(16)
+		nonce    = randomHex(16)
+		verifier = oauth2.GenerateVerifier()
+	)
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state + "." + nonce + "." + verifier,
+		MaxAge:   int(oidcStateTTL / time.Second),
+		Path:     "/backend/oidc/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(rw, req, config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), http.StatusFound)
+}
+
+// serveOIDCCallback completes the sign in, maps the identity to a user and issues a token
+func (a *App) serveOIDCCallback(rw http.ResponseWriter, req *http.Request) {
+	if !a.oidc.opts.Enabled {
+		http.NotFound(rw, req)
+		return
+	}
+
+	// consume the state cookie
+	http.SetCookie(rw, &http.Cookie{
+		Name:   oidcStateCookie,
+		MaxAge: -1,
+		Path:   "/backend/oidc/",
+	})
+
+	ident, err := a.oidcAuthenticate(req)
+	if err != nil {
+		a.log.With("error", err).Info("oidc sign in")
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var user *model.User
+	if user, err = a.oidcUser(req, ident); err != nil {
+		a.auditFailure(req, ident.Username, "user.sign_in", ident.Username, err.Error())
+		a.log.With("username", ident.Username, "error", err).Info("oidc sign in")
+		http.Error(rw, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	a.log.With("username", user.ID, "mfa", ident.MFA).Info("oidc sign in")
+
+	// second factor of bunker is still required, unless done by identity provider
+	if user.TOTPEnabled && !ident.MFA {
+		var challenge *model.MFAChallenge
+		if challenge, err = a.createMFAChallenge(user.ID, req.UserAgent(), "oidc"); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(rw, req, "/?challenge="+challenge.ID, http.StatusFound)
+		return
+	}
+
+	var (
+		token  *model.Token
+		cookie *http.Cookie
+	)
+	if token, cookie, err = a.createToken(user.ID, req.UserAgent(), requestIP(req), ident.MFA); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	a.auditSignIn(req, token, "oidc")
+
+	http.SetCookie(rw, cookie)
+	http.Redirect(rw, req, "/dashboard", http.StatusFound)
+}
+
+func (a *App) oidcAuthenticate(req *http.Request) (ident oidcIdentity, err error) {
+	q := req.URL.Query()
+
+	if e := q.Get("error"); e != "" {
+		err = errors.New("identity provider error: " + e + " " + q.Get("error_description"))
+		return
+	}
+
+	var cookie *http.Cookie
+	if cookie, err = req.Cookie(oidcStateCookie); err != nil {
+		err = errors.New("sign in expired, try again")
+		return
+	}
+
+	splits := strings.Split(cookie.Value, ".")
+	if len(splits) != 3 || splits[0] != q.Get("state") {
+		err = errors.New("invalid state")
+		return
+	}
+
+	var (
+		nonce    = splits[1]
+		verifier = splits[2]
+	)
+
+	config, idVerifier, err := a.oidc.get(req.Context())
+	if err != nil {
+		return
+	}
+
+	var token *oauth2.Token
+	if token, err = config.Exchange(req.Context(), q.Get("code"), oauth2.VerifierOption(verifier)); err != nil {
+		return
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		err = errors.New("missing id_token")
+		return
+	}
+
+	var idToken *oidc.IDToken
+	if idToken, err = idVerifier.Verify(req.Context(), rawIDToken); err != nil {
+		return
+	}
+	if idToken.Nonce != nonce {
+		err = errors.New("invalid nonce")
+		return
+	}
+
+	var claims map[string]any
+	if err = idToken.Claims(&claims); err != nil {
+		return
+	}
+
+	return a.oidc.identity(claims)
+}
+
+// oidcUser finds or provisions the user of identity, and syncs admin status of users managed by oidc from groups
+func (a *App) oidcUser(req *http.Request, ident oidcIdentity) (user *model.User, err error) {
+	db := dao.Use(a.db)
+
+	manageAdmin := len(a.oidc.opts.AdminGroups) > 0
+	isAdmin := a.oidc.isAdmin(ident.Groups)
+
+	if user, err = db.User.Where(db.User.ID.Eq(ident.Username)).First(); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+		if !a.oidc.opts.AutoProvision {
+			err = errors.New("user " + ident.Username + " not found")
+			return
+		}
+
+		user = &model.User{
+			ID:        ident.Username,
+			CreatedAt: time.Now(),
+			VisitedAt: time.Now(),
+			IsAdmin:   manageAdmin && isAdmin,
+			Source:    model.UserSourceOIDC,
+		}
+		if err = db.User.Create(user); err != nil {
+			return
+		}
+		a.log.With("username", user.ID, "is_admin", user.IsAdmin).Info("oidc user provisioned")
+
+		a.auditor.Record(&model.AuditEvent{
+			Actor:    user.ID,
+			Action:   "user.create",
+			Target:   user.ID,
+			Reason:   "oidc auto provision",
+			After:    auditState(user),
+			SourceIP: requestIP(req),
+		})
+	} else if user.Source != model.UserSourceOIDC {
+		if !a.oidc.opts.LinkUsers {
+			user, err = nil, errors.New("user "+ident.Username+" is not managed by oidc")
+			return
+		}
+	} else if manageAdmin && user.IsAdmin != isAdmin {
+		if _, err = db.User.Where(db.User.ID.Eq(user.ID)).UpdateColumnSimple(db.User.IsAdmin.Value(isAdmin)); err != nil {
+			return
+		}
+		before := *user
+		user.IsAdmin = isAdmin
+
+		a.auditor.Record(&model.AuditEvent{
+			Action:   "user.update",
+			Target:   user.ID,
+			Reason:   "oidc admin groups",
+			Before:   auditState(before),
+			After:    auditState(user),
+			SourceIP: requestIP(req),
+		})
+	}
+
+	if user.IsBlocked {
+		err = errUserBlocked
+		return
+	}
+	return
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,530 @@
+package bunker
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const testOIDCClientID = "bunker"
+
+// testIdP is a stand-in identity provider with discovery, jwks and token endpoints, authorization is done by authorize
+type testIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]testIdPGrant
+}
+
+type testIdPGrant struct {
+	nonce     string
+	challenge string
+	claims    map[string]any
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &testIdP{key: key, codes: map[string]testIdPGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(rw http.ResponseWriter, req *http.Request) {
+		writeTestJSON(rw, http.StatusOK, map[string]any{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(rw http.ResponseWriter, req *http.Request) {
+		writeTestJSON(rw, http.StatusOK, map[string]any{
+			"keys": []map[string]any{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", idp.serveToken)
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func writeTestJSON(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(v)
+}
+
+// authorize approves the authorization request of location with claims, returns query of the callback
+func (idp *testIdP) authorize(t *testing.T, location string, claims map[string]any) url.Values {
+	t.Helper()
+
+	u, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+
+	if !strings.HasPrefix(location, idp.server.URL+"/authorize?") {
+		t.Fatalf("unexpected authorization url %s", location)
+	}
+	if q.Get("client_id") != testOIDCClientID || q.Get("response_type") != "code" {
+		t.Fatalf("unexpected authorization request %s", location)
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("authorization request without pkce %s", location)
+	}
+	if q.Get("state") == "" || q.Get("nonce") == "" {
+		t.Fatalf("authorization request without state or nonce %s", location)
+	}
+
+	code := randomHex(8)
+
+	idp.mu.Lock()
+	idp.codes[code] = testIdPGrant{nonce: q.Get("nonce"), challenge: q.Get("code_challenge"), claims: claims}
+	idp.mu.Unlock()
+
+	return url.Values{"code": {code}, "state": {q.Get("state")}}
+}
+
+func (idp *testIdP) serveToken(rw http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+
+	idp.mu.Lock()
+	grant, ok := idp.codes[req.Form.Get("code")]
+	delete(idp.codes, req.Form.Get("code"))
+	idp.mu.Unlock()
+
+	if !ok {
+		writeTestJSON(rw, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(req.Form.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		writeTestJSON(rw, http.StatusBadRequest, map[string]any{"error": "invalid_grant", "error_description": "pkce verification failed"})
+		return
+	}
+
+	claims := map[string]any{
+		"iss":   idp.server.URL,
+		"aud":   testOIDCClientID,
+		"sub":   randomHex(8),
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": grant.nonce,
+	}
+	for k, v := range grant.claims {
+		claims[k] = v
+	}
+
+	writeTestJSON(rw, http.StatusOK, map[string]any{
+		"access_token": randomHex(16),
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     idp.sign(claims),
+	})
+}
+
+func (idp *testIdP) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]any{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	sum := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, sum[:])
+	if err != nil {
+		panic(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func createTestOIDCApp(t *testing.T, idp *testIdP, opts oidcOptions) *App {
+	t.Helper()
+
+	db := createTestDatabase(t)
+	log := zap.NewNop().Sugar()
+
+	opts.Enabled = true
+	opts.Issuer = idp.server.URL
+	opts.ClientID = testOIDCClientID
+	opts.RedirectURL = "http://bunker.example.com/backend/oidc/callback"
+	opts.Scopes = []string{"openid", "profile"}
+	opts.UsernameClaim = "preferred_username"
+	opts.GroupsClaim = "groups"
+
+	return &App{
+		db:  db,
+		log: log,
+		auditor: &Auditor{
+			db:       db,
+			webhooks: &WebhookDispatcher{db: db, log: log, notify: make(chan struct{}, 1)},
+			log:      log,
+		},
+		authOpts: authOptions{TokenLifetime: 3600},
+		oidc:     &oidcClient{opts: opts},
+	}
+}
+
+func findTestCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name && cookie.MaxAge >= 0 {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	idp := newTestIdP(t)
+
+	tests := []struct {
+		name   string
+		opts   oidcOptions
+		users  []*model.User
+		claims map[string]any
+		// tampers the callback request
+		tamper func(q url.Values, cookie *http.Cookie) *http.Cookie
+		// expected status, redirect location prefix if 302
+		status   int
+		location string
+		check    func(t *testing.T, db *gorm.DB)
+	}{
+		{
+			name:   "state mismatch",
+			opts:   oidcOptions{AutoProvision: true},
+			claims: map[string]any{"preferred_username": "alice"},
+			tamper: func(q url.Values, cookie *http.Cookie) *http.Cookie {
+				q.Set("state", "forged")
+				return cookie
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "state cookie missing",
+			opts:   oidcOptions{AutoProvision: true},
+			claims: map[string]any{"preferred_username": "alice"},
+			tamper: func(q url.Values, cookie *http.Cookie) *http.Cookie {
+				return nil
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "nonce mismatch",
+			opts:   oidcOptions{AutoProvision: true},
+			claims: map[string]any{"preferred_username": "alice", "nonce": "forged"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "pkce verifier mismatch",
+			opts:   oidcOptions{AutoProvision: true},
+			claims: map[string]any{"preferred_username": "alice"},
+			tamper: func(q url.Values, cookie *http.Cookie) *http.Cookie {
+				splits := strings.Split(cookie.Value, ".")
+				cookie.Value = splits[0] + "." + splits[1] + "." + strings.Repeat("x", 43)
+				return cookie
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "identity provider error",
+			opts:   oidcOptions{AutoProvision: true},
+			claims: map[string]any{"preferred_username": "alice"},
+			tamper: func(q url.Values, cookie *http.Cookie) *http.Cookie {
+				q.Set("error", "access_denied")
+				return cookie
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid username",
+			opts:   oidcOptions{AutoProvision: true},
+			claims: map[string]any{"preferred_username": "", "email": "alice@example.com"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:     "auto provision",
+			opts:     oidcOptions{AutoProvision: true},
+			claims:   map[string]any{"preferred_username": "Alice"},
+			status:   http.StatusFound,
+			location: "/dashboard",
+			check: func(t *testing.T, _db *gorm.DB) {
+				user := reloadTestUser(t, _db, "alice")
+				if user.Source != model.UserSourceOIDC || user.IsAdmin {
+					t.Fatalf("unexpected provisioned user %+v", user)
+				}
+			},
+		},
+		{
+			name:   "unknown user without auto provision",
+			claims: map[string]any{"preferred_username": "alice"},
+			status: http.StatusForbidden,
+			check: func(t *testing.T, _db *gorm.DB) {
+				db := dao.Use(_db)
+				if n, _ := db.User.Where(db.User.ID.Eq("alice")).Count(); n != 0 {
+					t.Fatal("user provisioned without auto_provision")
+				}
+			},
+		},
+		{
+			name:   "local user not linked",
+			opts:   oidcOptions{AutoProvision: true},
+			users:  []*model.User{{ID: "alice", Source: model.UserSourceLocal}},
+			claims: map[string]any{"preferred_username": "alice"},
+			status: http.StatusForbidden,
+		},
+		{
+			name:     "local user linked",
+			opts:     oidcOptions{LinkUsers: true, AdminGroups: []string{"ops"}},
+			users:    []*model.User{{ID: "alice", Source: model.UserSourceLocal}},
+			claims:   map[string]any{"preferred_username": "alice", "groups": []string{"ops"}},
+			status:   http.StatusFound,
+			location: "/dashboard",
+			check: func(t *testing.T, _db *gorm.DB) {
+				user := reloadTestUser(t, _db, "alice")
+				if user.Source != model.UserSourceLocal || user.IsAdmin {
+					t.Fatalf("linked user changed %+v", user)
+				}
+			},
+		},
+		{
+			name:     "admin group on provision",
+			opts:     oidcOptions{AutoProvision: true, AdminGroups: []string{"ops"}},
+			claims:   map[string]any{"preferred_username": "alice", "groups": []string{"dev", "ops"}},
+			status:   http.StatusFound,
+			location: "/dashboard",
+			check: func(t *testing.T, _db *gorm.DB) {
+				if !reloadTestUser(t, _db, "alice").IsAdmin {
+					t.Fatal("expected admin from groups")
+				}
+			},
+		},
+		{
+			name:     "admin group as single string",
+			opts:     oidcOptions{AutoProvision: true, AdminGroups: []string{"ops"}},
+			claims:   map[string]any{"preferred_username": "alice", "groups": "ops"},
+			status:   http.StatusFound,
+			location: "/dashboard",
+			check: func(t *testing.T, _db *gorm.DB) {
+				if !reloadTestUser(t, _db, "alice").IsAdmin {
+					t.Fatal("expected admin from groups")
+				}
+			},
+		},
+		{
+			name:     "admin revoked when leaving group",
+			opts:     oidcOptions{AdminGroups: []string{"ops"}},
+			users:    []*model.User{{ID: "alice", Source: model.UserSourceOIDC, IsAdmin: true}},
+			claims:   map[string]any{"preferred_username": "alice", "groups": []string{"dev"}},
+			status:   http.StatusFound,
+			location: "/dashboard",
+			check: func(t *testing.T, _db *gorm.DB) {
+				if reloadTestUser(t, _db, "alice").IsAdmin {
+					t.Fatal("expected admin revoked")
+				}
+			},
+		},
+		{
+			name:     "admin not managed without admin groups",
+			users:    []*model.User{{ID: "alice", Source: model.UserSourceOIDC, IsAdmin: true}},
+			claims:   map[string]any{"preferred_username": "alice"},
+			status:   http.StatusFound,
+			location: "/dashboard",
+			check: func(t *testing.T, _db *gorm.DB) {
+				if !reloadTestUser(t, _db, "alice").IsAdmin {
+					t.Fatal("expected admin kept")
+				}
+			},
+		},
+		{
+			name:   "blocked user",
+			users:  []*model.User{{ID: "alice", Source: model.UserSourceOIDC, IsBlocked: true}},
+			claims: map[string]any{"preferred_username": "alice"},
+			status: http.StatusForbidden,
+		},
+		{
+			name:     "totp challenge of bunker",
+			users:    []*model.User{{ID: "alice", Source: model.UserSourceOIDC, TOTPEnabled: true, TOTPSecret: model.GenerateTOTPSecret()}},
+			claims:   map[string]any{"preferred_username": "alice", "amr": []string{"pwd"}},
+			status:   http.StatusFound,
+			location: "/?challenge=",
+		},
+		{
+			name:     "totp skipped with mfa of identity provider",
+			users:    []*model.User{{ID: "alice", Source: model.UserSourceOIDC, TOTPEnabled: true, TOTPSecret: model.GenerateTOTPSecret()}},
+			claims:   map[string]any{"preferred_username": "alice", "amr": []string{"pwd", "mfa"}},
+			status:   http.StatusFound,
+			location: "/dashboard",
+		},
+		{
+			name:     "totp skipped with otp of identity provider",
+			users:    []*model.User{{ID: "alice", Source: model.UserSourceOIDC, TOTPEnabled: true, TOTPSecret: model.GenerateTOTPSecret()}},
+			claims:   map[string]any{"preferred_username": "alice", "amr": []string{"otp"}},
+			status:   http.StatusFound,
+			location: "/dashboard",
+		},
+		{
+			name:     "amr not a list",
+			users:    []*model.User{{ID: "alice", Source: model.UserSourceOIDC, TOTPEnabled: true, TOTPSecret: model.GenerateTOTPSecret()}},
+			claims:   map[string]any{"preferred_username": "alice", "amr": "mfa"},
+			status:   http.StatusFound,
+			location: "/?challenge=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := createTestOIDCApp(t, idp, tt.opts)
+			db := dao.Use(a.db)
+
+			for _, user := range tt.users {
+				user.CreatedAt, user.VisitedAt = time.Now(), time.Now()
+				if err := db.User.Create(user); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// redirected to identity provider
+			rec := httptest.NewRecorder()
+			a.serveOIDCSignIn(rec, httptest.NewRequest(http.MethodGet, "/backend/oidc/sign_in", nil))
+			if rec.Code != http.StatusFound {
+				t.Fatalf("sign in: expected redirect, got %d %s", rec.Code, rec.Body.String())
+			}
+
+			cookie := findTestCookie(rec, oidcStateCookie)
+			if cookie == nil {
+				t.Fatal("sign in: state cookie not set")
+			}
+
+			q := idp.authorize(t, rec.Header().Get("Location"), tt.claims)
+
+			if tt.tamper != nil {
+				cookie = tt.tamper(q, cookie)
+			}
+
+			// redirected back from identity provider
+			req := httptest.NewRequest(http.MethodGet, "/backend/oidc/callback?"+q.Encode(), nil)
+			if cookie != nil {
+				req.AddCookie(cookie)
+			}
+			rec = httptest.NewRecorder()
+			a.serveOIDCCallback(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("callback: expected %d, got %d %s", tt.status, rec.Code, rec.Body.String())
+			}
+
+			location := rec.Header().Get("Location")
+			if !strings.HasPrefix(location, tt.location) {
+				t.Fatalf("callback: expected location %s, got %s", tt.location, location)
+			}
+
+			token := findTestCookie(rec, "token")
+
+			switch {
+			case tt.location == "/dashboard":
+				if token == nil {
+					t.Fatal("callback: token cookie not set")
+				}
+				issued, err := db.Token.Where(db.Token.ID.Eq(token.Value)).First()
+				if err != nil {
+					t.Fatal(err)
+				}
+				amr, _ := tt.claims["amr"].([]string)
+				if mfa := strings.Contains(strings.Join(amr, ","), "mfa") || strings.Contains(strings.Join(amr, ","), "otp"); issued.MFA != mfa {
+					t.Fatalf("callback: expected token mfa %v, got %v", mfa, issued.MFA)
+				}
+			case strings.HasPrefix(tt.location, "/?challenge="):
+				if token != nil {
+					t.Fatal("callback: token issued before second factor")
+				}
+				challenge, err := db.MFAChallenge.Where(db.MFAChallenge.ID.Eq(strings.TrimPrefix(location, "/?challenge="))).First()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if challenge.UserID != "alice" || challenge.Method != "oidc" {
+					t.Fatalf("callback: unexpected challenge %+v", challenge)
+				}
+			default:
+				if token != nil {
+					t.Fatal("callback: token issued on failure")
+				}
+			}
+
+			if tt.check != nil {
+				tt.check(t, a.db)
+			}
+		})
+	}
+}
+
+func TestOIDCStateSingleUse(t *testing.T) {
+	idp := newTestIdP(t)
+	a := createTestOIDCApp(t, idp, oidcOptions{AutoProvision: true})
+
+	rec := httptest.NewRecorder()
+	a.serveOIDCSignIn(rec, httptest.NewRequest(http.MethodGet, "/backend/oidc/sign_in", nil))
+	cookie := findTestCookie(rec, oidcStateCookie)
+
+	q := idp.authorize(t, rec.Header().Get("Location"), map[string]any{"preferred_username": "alice"})
+
+	req := httptest.NewRequest(http.MethodGet, "/backend/oidc/callback?"+q.Encode(), nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	a.serveOIDCCallback(rec, req)
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected redirect, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// the state cookie is cleared
+	var cleared bool
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oidcStateCookie && c.MaxAge < 0 {
+			cleared = true
+		}
+	}
+	if !cleared {
+		t.Fatal("state cookie not cleared")
+	}
+
+	// replaying the callback fails, the code is consumed by identity provider
+	req = httptest.NewRequest(http.MethodGet, "/backend/oidc/callback?"+q.Encode(), nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	a.serveOIDCCallback(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected replay rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,511 @@
+package bunker
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	errPickerCancelled = errors.New("server picker cancelled")
+)
+
+// PickedChannel is a session channel on which the user picked a server interactively
+type PickedChannel struct {
+	Server     *model.Server
+	ServerUser string
+
+	Channel  ssh.Channel
+	Requests <-chan *ssh.Request
+	// requests already replied by bunker, should be replayed to the target
+	Replay []*ssh.Request
+}
+
+type pickerItem struct {
+	ServerUser string
+	ServerID   string
+}
+
+func (i pickerItem) String() string {
+	return i.ServerUser + "@" + i.ServerID
+}
+
+func (i pickerItem) Match(terms []string) bool {
+	s := strings.ToLower(i.String())
+	for _, term := range terms {
+		if !strings.Contains(s, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// pickerRequests handles requests of the session channel while picking a server,
+// and forwards them once the channel is piped
+type pickerRequests struct {
+	mu     sync.Mutex
+	env    []*ssh.Request
+	pty    *sshPtyRequest
+	shell  bool
+	piped  bool
+	out    chan *ssh.Request
+	resize chan struct{}
+	start  chan struct{}
+	done   chan struct{}
+}
+
+func newPickerRequests(in <-chan *ssh.Request) *pickerRequests {
+	pr := &pickerRequests{
+		out:    make(chan *ssh.Request),
+		resize: make(chan struct{}, 1),
+		start:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go pr.run(in)
+	return pr
+}
+
+func (pr *pickerRequests) run(in <-chan *ssh.Request) {
+	defer close(pr.out)
+	defer close(pr.done)
+
+	for req := range in {
+		pr.mu.Lock()
+		if pr.piped {
+			pr.mu.Unlock()
+			pr.out <- req
+			continue
+		}
+
+		ok := true
+
+		switch req.Type {
+		case "env":
+			pr.env = append(pr.env, &ssh.Request{Type: req.Type, Payload: req.Payload})
+		case "pty-req":
+			var p sshPtyRequest
+			if ok = ssh.Unmarshal(req.Payload, &p) == nil; ok {
+				pr.pty = &p
+			}
+		case "window-change":
+			var p sshWindowChangeRequest
+			if ssh.Unmarshal(req.Payload, &p) == nil && pr.pty != nil {
+				pr.pty.Columns, pr.pty.Rows = p.Columns, p.Rows
+				pr.pty.Width, pr.pty.Height = p.Width, p.Height
+				select {
+				case pr.resize <- struct{}{}:
+				default:
+				}
+			}
+		case "shell":
+			if !pr.shell {
+				pr.shell = true
+				close(pr.start)
+			}
+		default:
+			// commands and subsystems are not supported without a server
+			ok = false
+		}
+		pr.mu.Unlock()
+
+		if req.WantReply {
+			req.Reply(ok, nil)
+		}
+	}
+}
+
+func (pr *pickerRequests) size() (width int, height int) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	if pr.pty == nil || pr.pty.Columns == 0 || pr.pty.Rows == 0 {
+		return recordingDefaultWidth, recordingDefaultHeight
+	}
+	return int(pr.pty.Columns), int(pr.pty.Rows)
+}
+
+// pipe stops handling requests, returns requests to replay
+func (pr *pickerRequests) pipe() (replay []*ssh.Request) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	pr.piped = true
+
+	replay = append(replay, pr.env...)
+	if pr.pty != nil {
+		replay = append(replay, &ssh.Request{Type: "pty-req", WantReply: true, Payload: ssh.Marshal(pr.pty)})
+	}
+	replay = append(replay, &ssh.Request{Type: "shell", WantReply: true})
+	return
+}
+
+type pickerInput struct {
+	buf []byte
+	err error
+}
+
+// PickServer waits for a session channel with shell, and lets the user pick a granted server interactively
+func (s *SSHServer) PickServer(userID string, sourceIP string, mfa bool, chUserNewChannel <-chan ssh.NewChannel, chUserRequest <-chan *ssh.Request) (picked *PickedChannel, err error) {
+	db := dao.Use(s.db)
+
+	var user *model.User
+	if user, err = db.User.Where(db.User.ID.Eq(userID)).First(); err != nil {
+		return
+	}
+
+	// wait for the first session channel
+	var (
+		channel    ssh.Channel
+		chRequests <-chan *ssh.Request
+	)
+
+	for channel == nil {
+		select {
+		case nc, ok := <-chUserNewChannel:
+			if !ok {
+				err = errPickerCancelled
+				return
+			}
+			if nc.ChannelType() != "session" {
+				nc.Reject(ssh.Prohibited, "no server selected")
+				continue
+			}
+			if channel, chRequests, err = nc.Accept(); err != nil {
+				return
+			}
+		case req, ok := <-chUserRequest:
+			if !ok {
+				err = errPickerCancelled
+				return
+			}
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+
+	// reject other channels and requests while picking
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		chNewChannel, chRequest := chUserNewChannel, chUserRequest
+		for {
+			select {
+			case nc, ok := <-chNewChannel:
+				if !ok {
+					chNewChannel = nil
+					continue
+				}
+				nc.Reject(ssh.Prohibited, "no server selected")
+			case req, ok := <-chRequest:
+				if !ok {
+					chRequest = nil
+					continue
+				}
+				if req.WantReply {
+					req.Reply(false, nil)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	pr := newPickerRequests(chRequests)
+
+	exit := func(msg string) {
+		if msg != "" {
+			channel.Stderr().Write([]byte("bunker: " + msg + "\r\n"))
+		}
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{1}))
+		channel.Close()
+	}
+
+	select {
+	case <-pr.start:
+	case <-pr.done:
+		channel.Close()
+		err = errPickerCancelled
+		return
+	}
+
+	// collect granted items
+	var granted map[string][]string
+	if granted, err = GrantedServerUsers(s.db, user); err != nil {
+		exit(err.Error())
+		return
+	}
+
+	var items []pickerItem
+	for serverID, serverUsers := range granted {
+		seen := map[string]bool{}
+		for _, serverUser := range serverUsers {
+			if seen[serverUser] {
+				continue
+			}
+			seen[serverUser] = true
+			items = append(items, pickerItem{ServerUser: serverUser, ServerID: serverID})
+		}
+	}
+
+	if len(items) == 0 {
+		exit("no server granted")
+		err = errors.New("no server granted")
+		return
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].ServerID == items[j].ServerID {
+			return items[i].ServerUser < items[j].ServerUser
+		}
+		return items[i].ServerID < items[j].ServerID
+	})
+
+	// read input one chunk at a time, never read beyond the selection
+	chInput, chNext := make(chan pickerInput), make(chan bool)
+	go func() {
+		for {
+			buf := make([]byte, 256)
+			n, err := channel.Read(buf)
+			chInput <- pickerInput{buf: buf[:n], err: err}
+			if err != nil || !<-chNext {
+				return
+			}
+		}
+	}()
+
+	var (
+		query    string
+		selected int
+		matched  []pickerItem
+		chosen   *pickerItem
+		hint     string
+	)
+
+	authorize := func(serverUser string, serverID string) (server *model.Server, err error) {
+		if server, err = AuthorizeServerAccess(s.db, user, serverUser, serverID); err != nil {
+			return
+		}
+		if err = s.policies.Authorize(PolicyInput{
+			User:       user,
+			Server:     server,
+			ServerUser: serverUser,
+			SourceIP:   sourceIP,
+			Time:       time.Now(),
+		}); err != nil {
+			return
+		}
+		if server.RequireMFA && !mfa {
+			err = errors.New("server requires mfa, enable totp first")
+		}
+		return
+	}
+
+	filter := func() {
+		terms := strings.Fields(strings.ToLower(query))
+		matched = matched[:0]
+		for _, item := range items {
+			if item.Match(terms) {
+				matched = append(matched, item)
+			}
+		}
+		if selected >= len(matched) {
+			selected = len(matched) - 1
+		}
+		if selected < 0 {
+			selected = 0
+		}
+	}
+
+	render := func() {
+		width, height := pr.size()
+		if chosen == nil {
+			renderPicker(channel, query, matched, selected, hint, width, height)
+		} else {
+			renderServerUserPrompt(channel, *chosen, query, hint)
+		}
+	}
+
+	filter()
+	render()
+
+	for {
+		var in pickerInput
+
+		select {
+		case <-pr.resize:
+			render()
+			continue
+		case in = <-chInput:
+		}
+
+		if in.err != nil {
+			channel.Close()
+			err = errPickerCancelled
+			return
+		}
+
+		var (
+			submit bool
+			cancel bool
+		)
+
+		for i := 0; i < len(in.buf) && !submit && !cancel; i++ {
+			switch b := in.buf[i]; b {
+			case 0x03, 0x04:
+				cancel = true
+			case '\r', '\n':
+				submit = true
+			case 0x7f, 0x08:
+				if len(query) > 0 {
+					query = query[:len(query)-1]
+				}
+			case 0x10:
+				selected--
+			case 0x0e:
+				selected++
+			case 0x1b:
+				if i+2 < len(in.buf) && (in.buf[i+1] == '[' || in.buf[i+1] == 'O') {
+					switch in.buf[i+2] {
+					case 'A':
+						selected--
+					case 'B':
+						selected++
+					}
+					i += 2
+				}
+			default:
+				if b >= 0x20 && b < 0x7f {
+					query += string(b)
+				}
+			}
+		}
+
+		if cancel {
+			chNext <- false
+			exit("")
+			err = errPickerCancelled
+			return
+		}
+
+		hint = ""
+
+		if chosen == nil {
+			filter()
+
+			if submit && len(matched) > 0 {
+				item := matched[selected]
+				if strings.ContainsAny(item.ServerUser, "*?[") {
+					// wildcard server user, ask for a concrete one
+					chosen = &item
+					query = ""
+				} else {
+					var server *model.Server
+					if server, err = authorize(item.ServerUser, item.ServerID); err == nil {
+						chNext <- false
+						picked = &PickedChannel{Server: server, ServerUser: item.ServerUser}
+						break
+					}
+					hint = err.Error()
+				}
+			}
+		} else if submit {
+			if query == "" {
+				// back to server list
+				chosen = nil
+				filter()
+			} else {
+				var server *model.Server
+				if server, err = authorize(query, chosen.ServerID); err == nil {
+					chNext <- false
+					picked = &PickedChannel{Server: server, ServerUser: query}
+					break
+				}
+				hint = err.Error()
+			}
+		}
+
+		render()
+		chNext <- true
+	}
+
+	channel.Write([]byte("\x1b[H\x1b[2J" + "bunker: connecting to " + picked.ServerUser + "@" + picked.Server.ID + "\r\n"))
+
+	picked.Channel = channel
+	picked.Requests = pr.out
+	picked.Replay = pr.pipe()
+	return
+}
+
+func renderPicker(ch ssh.Channel, query string, items []pickerItem, selected int, hint string, width int, height int) {
+	var sb strings.Builder
+
+	sb.WriteString("\x1b[H\x1b[2J")
+	sb.WriteString(truncateLine("bunker: select a server, type to filter, up/down to move, enter to connect, ctrl-c to quit", width))
+	sb.WriteString("\r\n")
+	if hint != "" {
+		sb.WriteString(truncateLine("bunker: "+hint, width))
+	}
+	sb.WriteString("\r\n")
+
+	// scroll the list to keep the selected item visible
+	rows := height - 4
+	if rows < 1 {
+		rows = 1
+	}
+	offset := 0
+	if selected >= rows {
+		offset = selected - rows + 1
+	}
+
+	for i := offset; i < len(items) && i < offset+rows; i++ {
+		line := truncateLine(items[i].String(), width-2)
+		if i == selected {
+			sb.WriteString("> \x1b[7m" + line + "\x1b[0m\r\n")
+		} else {
+			sb.WriteString("  " + line + "\r\n")
+		}
+	}
+	if len(items) == 0 {
+		sb.WriteString("  (no match)\r\n")
+	}
+
+	// move cursor to the query line at bottom
+	sb.WriteString("\x1b[" + strconv.Itoa(height) + ";1H")
+	sb.WriteString("filter: " + query)
+
+	ch.Write([]byte(sb.String()))
+}
+
+func renderServerUserPrompt(ch ssh.Channel, item pickerItem, query string, hint string) {
+	var sb strings.Builder
+
+	sb.WriteString("\x1b[H\x1b[2J")
+	sb.WriteString(fmt.Sprintf("bunker: server users matching %q are granted on %s, empty to go back\r\n", item.ServerUser, item.ServerID))
+	if hint != "" {
+		sb.WriteString("bunker: " + hint)
+	}
+	sb.WriteString("\r\n")
+	sb.WriteString("server user: " + query)
+
+	ch.Write([]byte(sb.String()))
+}
+
+func truncateLine(s string, width int) string {
+	if width > 0 && len(s) > width {
+		return s[:width]
+	}
+	return s
+}
@@ -0,0 +1,246 @@
+package bunker
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	errPolicyDenied = errors.New("denied by policy")
+)
+
+// PolicyInput is the access being authorized, exposed to policy expressions
+type PolicyInput struct {
+	User       *model.User
+	Server     *model.Server
+	ServerUser string
+	SourceIP   string
+	Time       time.Time
+}
+
+// PolicyDecision is the result of a single policy
+type PolicyDecision struct {
+	PolicyID string `json:"policy_id"`
+	Mode     string `json:"mode"`
+	Denied   bool   `json:"denied"`
+	// failed evaluation denies access, unless in dry run
+	Error string `json:"error,omitempty"`
+}
+
+// Result returns allow, deny or error
+func (d PolicyDecision) Result() string {
+	if d.Error != "" {
+		return "error"
+	}
+	if d.Denied {
+		return "deny"
+	}
+	return "allow"
+}
+
+// PolicyEngine evaluates CEL policies after grants allowed the access
+type PolicyEngine struct {
+	db  *gorm.DB
+	log *zap.SugaredLogger
+	env *cel.Env
+
+	mu       sync.Mutex
+	programs map[string]cel.Program
+}
+
+type PolicyEngineOptions struct {
+	fx.In
+
+	DB     *gorm.DB
+	Logger *zap.SugaredLogger
+}
+
+func CreatePolicyEngine(opts PolicyEngineOptions) (pe *PolicyEngine, err error) {
+	pe = &PolicyEngine{
+		db:       opts.DB,
+		log:      opts.Logger,
+		programs: map[string]cel.Program{},
+	}
+
+	pe.env, err = cel.NewEnv(
+		// id, is_admin, source, totp_enabled and groups
+		cel.Variable("user", cel.MapType(cel.StringType, cel.DynType)),
+		// id, address and labels
+		cel.Variable("server", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("server_user", cel.StringType),
+		cel.Variable("source_ip", cel.StringType),
+		cel.Variable("now", cel.TimestampType),
+		cel.Function("in_cidr",
+			cel.Overload("in_cidr_string_string", []*cel.Type{cel.StringType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(policyInCIDR),
+			),
+		),
+	)
+	return
+}
+
+// policyInCIDR implements in_cidr(ip, cidr)
+func policyInCIDR(ip ref.Val, cidr ref.Val) ref.Val {
+	addr, err := netip.ParseAddr(string(ip.(types.String)))
+	if err != nil {
+		return types.Bool(false)
+	}
+	prefix, err := netip.ParsePrefix(string(cidr.(types.String)))
+	if err != nil {
+		return types.NewErr("invalid cidr: %s", cidr)
+	}
+	return types.Bool(prefix.Contains(addr.Unmap()))
+}
+
+// Compile checks the expression, compiled programs are cached by expression
+func (pe *PolicyEngine) Compile(expr string) (prg cel.Program, err error) {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	if prg = pe.programs[expr]; prg != nil {
+		return
+	}
+
+	ast, iss := pe.env.Compile(expr)
+	if iss.Err() != nil {
+		err = iss.Err()
+		return
+	}
+	if ast.OutputType() != cel.BoolType {
+		err = errors.New("policy expression must evaluate to bool")
+		return
+	}
+
+	if prg, err = pe.env.Program(ast); err != nil {
+		return
+	}
+
+	pe.programs[expr] = prg
+	return
+}
+
+// Evaluate evaluates policies not disabled against the input, in order of policy id
+func (pe *PolicyEngine) Evaluate(input PolicyInput) (decisions []PolicyDecision, err error) {
+	db := dao.Use(pe.db)
+
+	decisions = []PolicyDecision{}
+
+	var policies []*model.Policy
+	if policies, err = db.Policy.Where(db.Policy.Mode.Neq(model.PolicyModeDisabled)).Order(db.Policy.ID).Find(); err != nil {
+		return
+	}
+
+	if len(policies) == 0 {
+		return
+	}
+
+	groups := []string{}
+	if err = db.GroupMember.Where(db.GroupMember.UserID.Eq(input.User.ID)).Pluck(db.GroupMember.GroupID, &groups); err != nil {
+		return
+	}
+
+	vars := map[string]any{
+		"user": map[string]any{
+			"id":           input.User.ID,
+			"is_admin":     input.User.IsAdmin,
+			"source":       input.User.Source,
+			"totp_enabled": input.User.TOTPEnabled,
+			"groups":       groups,
+		},
+		"server": map[string]any{
+			"id":      input.Server.ID,
+			"address": input.Server.Address,
+			"labels":  input.Server.LabelMap(),
+		},
+		"server_user": input.ServerUser,
+		"source_ip":   input.SourceIP,
+		"now":         input.Time,
+	}
+
+	for _, policy := range policies {
+		decision := PolicyDecision{PolicyID: policy.ID, Mode: policy.Mode}
+
+		if denied, err := pe.eval(policy.Expression, vars); err != nil {
+			decision.Error = err.Error()
+		} else {
+			decision.Denied = denied
+		}
+
+		decisions = append(decisions, decision)
+	}
+	return
+}
+
+func (pe *PolicyEngine) eval(expr string, vars map[string]any) (denied bool, err error) {
+	var prg cel.Program
+	if prg, err = pe.Compile(expr); err != nil {
+		return
+	}
+
+	var out ref.Val
+	if out, _, err = prg.Eval(vars); err != nil {
+		return
+	}
+
+	var ok bool
+	if denied, ok = out.Value().(bool); !ok {
+		err = errors.New("policy expression must evaluate to bool")
+	}
+	return
+}
+
+// Authorize returns errPolicyDenied if any enforced policy denies or fails, decisions of dry run policies are only logged
+func (pe *PolicyEngine) Authorize(input PolicyInput) (err error) {
+	var decisions []PolicyDecision
+	if decisions, err = pe.Evaluate(input); err != nil {
+		return
+	}
+
+	for _, decision := range decisions {
+		result := decision.Result()
+
+		metricPolicyDecisions.WithLabelValues(decision.PolicyID, decision.Mode, result).Inc()
+
+		log := pe.log.With(
+			"policy_id", decision.PolicyID,
+			"mode", decision.Mode,
+			"result", result,
+			"user_id", input.User.ID,
+			"target", input.ServerUser+"@"+input.Server.ID,
+			"source_ip", input.SourceIP,
+		)
+		if decision.Error != "" {
+			log = log.With("error", decision.Error)
+		}
+
+		if decision.Mode == model.PolicyModeDryRun {
+			log.Info("policy dry run")
+		} else if result != "allow" {
+			log.Info("policy denied")
+		}
+	}
+
+	return enforcePolicies(decisions)
+}
+
+// enforcePolicies returns errPolicyDenied with the first enforced policy denying or failed
+func enforcePolicies(decisions []PolicyDecision) error {
+	for _, decision := range decisions {
+		if decision.Mode == model.PolicyModeEnforce && decision.Result() != "allow" {
+			return fmt.Errorf("%w: %s", errPolicyDenied, decision.PolicyID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package bunker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	recordingDefaultWidth  = 80
+	recordingDefaultHeight = 24
+
+	// output arriving before the recording starts, i.e. right after the target accepted shell or exec
+	recordingMaxPending = 64 * 1024
+)
+
+type sshPtyRequest struct {
+	Term     string
+	Columns  uint32
+	Rows     uint32
+	Width    uint32
+	Height   uint32
+	Modelist string
+}
+
+type sshWindowChangeRequest struct {
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+}
+
+type sshExecRequest struct {
+	Command string
+}
+
+type recordingHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Command   string            `json:"command,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recording writes the output of a session channel as an asciicast v2 file,
+// the file is created lazily once the channel starts a shell or a command
+type Recording struct {
+	filename string
+
+	mu      sync.Mutex
+	file    *os.File
+	started time.Time
+	term    string
+	width   int
+	height  int
+	partial []byte
+	closed  bool
+	err     error
+}
+
+// NewRecording creates a new recording, nothing is written until Start is called
+func NewRecording(filename string) *Recording {
+	return &Recording{
+		filename: filename,
+		width:    recordingDefaultWidth,
+		height:   recordingDefaultHeight,
+	}
+}
+
+// HandleRequest inspects a forwarded channel request, tracking terminal dimensions and starting the recording
+func (r *Recording) HandleRequest(req *ssh.Request) {
+	switch req.Type {
+	case "pty-req":
+		var p sshPtyRequest
+		if ssh.Unmarshal(req.Payload, &p) == nil {
+			r.SetTerminal(p.Term, int(p.Columns), int(p.Rows))
+		}
+	case "window-change":
+		var p sshWindowChangeRequest
+		if ssh.Unmarshal(req.Payload, &p) == nil {
+			r.Resize(int(p.Columns), int(p.Rows))
+		}
+	case "shell":
+		r.Start("")
+	case "exec":
+		var p sshExecRequest
+		if ssh.Unmarshal(req.Payload, &p) == nil {
+			r.Start(p.Command)
+		}
+	}
+}
+
+// SetTerminal sets the terminal type and dimensions, emits a resize event if already started
+func (r *Recording) SetTerminal(term string, width, height int) {
+	r.mu.Lock()
+	r.term = term
+	r.mu.Unlock()
+
+	r.Resize(width, height)
+}
+
+// Resize updates the terminal dimensions, emits a resize event if already started
+func (r *Recording) Resize(width, height int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.width, r.height = width, height
+
+	if r.file != nil {
+		r.writeEvent("r", strconv.Itoa(width)+"x"+strconv.Itoa(height))
+	}
+}
+
+// Start creates the recording file and writes the asciicast header
+func (r *Recording) Start(command string) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file != nil || r.closed || r.err != nil {
+		return r.err
+	}
+
+	defer func() {
+		r.err = err
+	}()
+
+	if err = os.MkdirAll(filepath.Dir(r.filename), 0750); err != nil {
+		return
+	}
+
+	if r.file, err = os.OpenFile(r.filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640); err != nil {
+		return
+	}
+
+	r.started = time.Now()
+
+	header := recordingHeader{
+		Version:   2,
+		Width:     r.width,
+		Height:    r.height,
+		Timestamp: r.started.Unix(),
+		Command:   command,
+	}
+	if r.term != "" {
+		header.Env = map[string]string{"TERM": r.term}
+	}
+
+	var buf []byte
+	if buf, err = json.Marshal(header); err != nil {
+		return
+	}
+	if _, err = r.file.Write(append(buf, '\n')); err != nil {
+		return
+	}
+
+	r.writeOutput(nil)
+	return
+}
+
+// Write records output data, it never fails so that it can be used with io.MultiWriter
+func (r *Recording) Write(p []byte) (n int, err error) {
+	n = len(p)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		if !r.closed && len(r.partial)+len(p) <= recordingMaxPending {
+			r.partial = append(r.partial, p...)
+		}
+		return
+	}
+
+	r.writeOutput(p)
+	return
+}
+
+func (r *Recording) writeOutput(p []byte) {
+	// hold incomplete utf-8 sequence until next write
+	buf := append(r.partial, p...)
+	end := len(buf)
+	for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(buf[i]) {
+			if !utf8.FullRune(buf[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	r.partial = append([]byte(nil), buf[end:]...)
+
+	if end > 0 {
+		r.writeEvent("o", string(buf[:end]))
+	}
+}
+
+func (r *Recording) writeEvent(kind string, data string) {
+	if r.err != nil {
+		return
+	}
+
+	buf, err := json.Marshal([]any{time.Since(r.started).Seconds(), kind, data})
+	if err != nil {
+		r.err = err
+		return
+	}
+	if _, err = r.file.Write(append(buf, '\n')); err != nil {
+		r.err = err
+	}
+}
+
+// Close flushes pending data and closes the recording file
+func (r *Recording) Close() (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+
+	if r.file == nil {
+		return r.err
+	}
+
+	if len(r.partial) > 0 {
+		r.writeEvent("o", string(r.partial))
+		r.partial = nil
+	}
+
+	err = r.file.Close()
+	r.file = nil
+
+	if r.err != nil {
+		err = r.err
+	}
+	return
+}
@@ -0,0 +1,162 @@
+package bunker
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/yankeguo/bunker/model"
+)
+
+const (
+	selectorOpEquals       = "="
+	selectorOpNotEquals    = "!="
+	selectorOpIn           = "in"
+	selectorOpNotIn        = "notin"
+	selectorOpExists       = "exists"
+	selectorOpDoesNotExist = "!"
+)
+
+var selectorSetPattern = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+type selectorRequirement struct {
+	Key      string
+	Operator string
+	Values   []string
+}
+
+func (r selectorRequirement) String() string {
+	switch r.Operator {
+	case selectorOpExists:
+		return r.Key
+	case selectorOpDoesNotExist:
+		return "!" + r.Key
+	case selectorOpIn, selectorOpNotIn:
+		return r.Key + " " + r.Operator + " (" + strings.Join(r.Values, ",") + ")"
+	default:
+		return r.Key + r.Operator + r.Values[0]
+	}
+}
+
+func (r selectorRequirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+
+	switch r.Operator {
+	case selectorOpExists:
+		return ok
+	case selectorOpDoesNotExist:
+		return !ok
+	case selectorOpEquals:
+		return ok && value == r.Values[0]
+	case selectorOpNotEquals:
+		return !ok || value != r.Values[0]
+	case selectorOpIn:
+		return ok && slices.Contains(r.Values, value)
+	case selectorOpNotIn:
+		return !ok || !slices.Contains(r.Values, value)
+	default:
+		return false
+	}
+}
+
+// LabelSelector selects servers by labels, in syntax of kubernetes label selectors,
+// e.g. "env=prod,team in (payments,billing),tier!=db,!deprecated", an empty selector selects everything
+type LabelSelector []selectorRequirement
+
+// ParseLabelSelector parses the selector, requirements are sorted so equivalent selectors have the same String
+func ParseLabelSelector(s string) (sel LabelSelector, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return
+	}
+
+	for _, term := range splitSelector(s) {
+		var r selectorRequirement
+		if r, err = parseSelectorRequirement(strings.TrimSpace(term)); err != nil {
+			return
+		}
+		sel = append(sel, r)
+	}
+
+	sort.SliceStable(sel, func(i, j int) bool {
+		return sel[i].String() < sel[j].String()
+	})
+	return
+}
+
+// splitSelector splits by commas outside of parentheses
+func splitSelector(s string) (terms []string) {
+	var depth, start int
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, s[start:])
+}
+
+func parseSelectorRequirement(term string) (r selectorRequirement, err error) {
+	if term == "" {
+		err = errors.New("empty requirement in label selector")
+		return
+	}
+
+	if m := selectorSetPattern.FindStringSubmatch(term); m != nil {
+		r.Key, r.Operator = m[1], m[2]
+		for _, value := range strings.Split(m[3], ",") {
+			r.Values = append(r.Values, strings.TrimSpace(value))
+		}
+		sort.Strings(r.Values)
+		r.Values = slices.Compact(r.Values)
+	} else if key, ok := strings.CutPrefix(term, "!"); ok {
+		r.Key, r.Operator = strings.TrimSpace(key), selectorOpDoesNotExist
+	} else if key, value, ok := strings.Cut(term, "!="); ok {
+		r.Key, r.Operator, r.Values = strings.TrimSpace(key), selectorOpNotEquals, []string{strings.TrimSpace(value)}
+	} else if key, value, ok := strings.Cut(term, "=="); ok {
+		r.Key, r.Operator, r.Values = strings.TrimSpace(key), selectorOpEquals, []string{strings.TrimSpace(value)}
+	} else if key, value, ok := strings.Cut(term, "="); ok {
+		r.Key, r.Operator, r.Values = strings.TrimSpace(key), selectorOpEquals, []string{strings.TrimSpace(value)}
+	} else {
+		r.Key, r.Operator = term, selectorOpExists
+	}
+
+	if !model.LabelKeyPattern.MatchString(r.Key) {
+		err = errors.New("invalid label key in selector: " + r.Key)
+		return
+	}
+	for _, value := range r.Values {
+		if !model.LabelValuePattern.MatchString(value) {
+			err = errors.New("invalid label value in selector: " + value)
+			return
+		}
+	}
+	return
+}
+
+func (sel LabelSelector) String() string {
+	terms := make([]string, 0, len(sel))
+	for _, r := range sel {
+		terms = append(terms, r.String())
+	}
+	return strings.Join(terms, ",")
+}
+
+// Matches checks if labels satisfy all requirements
+func (sel LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range sel {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package bunker
+
+import "testing"
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		// normalized form, empty if invalid
+		expected string
+		invalid  bool
+	}{
+		{"", "", false},
+		{"  ", "", false},
+		{"env=prod", "env=prod", false},
+		{"env == prod", "env=prod", false},
+		{"env!=prod", "env!=prod", false},
+		{"team in (payments, billing, payments)", "team in (billing,payments)", false},
+		{"team notin (a,b)", "team notin (a,b)", false},
+		{"deprecated", "deprecated", false},
+		{"!deprecated", "!deprecated", false},
+		{"tier!=db,env=prod,team in (b,a),!deprecated", "!deprecated,env=prod,team in (a,b),tier!=db", false},
+		{"example.com/team=payments", "example.com/team=payments", false},
+		{"env=", "env=", false},
+		{"env=prod,", "", true},
+		{",env=prod", "", true},
+		{"-env=prod", "", true},
+		{"env=prod value", "", true},
+		{"env=prod!", "", true},
+		{"team in (a,b c)", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			sel, err := ParseLabelSelector(tt.selector)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("expected error, got %q", sel.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sel.String() != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, sel.String())
+			}
+		})
+	}
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{
+		"env":  "prod",
+		"team": "payments",
+		"tier": "",
+	}
+
+	tests := []struct {
+		selector string
+		matched  bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env=staging", false},
+		{"env!=staging", true},
+		{"env!=prod", false},
+		{"missing!=prod", true},
+		{"missing=prod", false},
+		{"team in (payments,billing)", true},
+		{"team in (billing)", false},
+		{"missing in (payments)", false},
+		{"team notin (billing)", true},
+		{"team notin (payments)", false},
+		{"missing notin (payments)", true},
+		{"tier", true},
+		{"missing", false},
+		{"!missing", true},
+		{"!env", false},
+		{"tier=", true},
+		{"env=prod,team in (payments),!deprecated", true},
+		{"env=prod,team in (billing)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			sel, err := ParseLabelSelector(tt.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched := sel.Matches(labels); matched != tt.matched {
+				t.Fatalf("expected matched %v, got %v", tt.matched, matched)
+			}
+		})
+	}
+}
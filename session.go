@@ -0,0 +1,213 @@
+package bunker
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SessionStats collects traffic and termination details of a proxied ssh connection
+type SessionStats struct {
+	BytesIn  atomic.Int64
+	BytesOut atomic.Int64
+	Channels atomic.Int64
+
+	mu          sync.Mutex
+	exitStatus  *int
+	closeReason string
+}
+
+// SetExitStatus records the exit status of a session channel, the last one wins
+func (s *SessionStats) SetExitStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exitStatus = &status
+}
+
+// ExitStatus returns the recorded exit status, nil if no exit status received
+func (s *SessionStats) ExitStatus() *int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exitStatus
+}
+
+// SetCloseReason records why the connection was closed, the first one wins
+func (s *SessionStats) SetCloseReason(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closeReason == "" {
+		s.closeReason = reason
+	}
+}
+
+// CloseReason returns the recorded close reason
+func (s *SessionStats) CloseReason() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeReason
+}
+
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Int64
+	m prometheus.Counter
+}
+
+func (c countingWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n.Add(int64(n))
+	if c.m != nil {
+		c.m.Add(float64(n))
+	}
+	return
+}
+
+// LiveSession is an active proxied connection tracked by SessionRegistry
+type LiveSession struct {
+	ID         string
+	UserID     string
+	ServerID   string
+	ServerUser string
+	RemoteAddr string
+	StartedAt  time.Time
+	Stats      *SessionStats
+	Shadow     *Shadow
+
+	channelIndex atomic.Int64
+
+	mu      sync.Mutex
+	closers []io.Closer
+}
+
+// NextChannelIndex returns the next channel index, starting from 1, used for naming recordings
+func (ls *LiveSession) NextChannelIndex() int64 {
+	return ls.channelIndex.Add(1)
+}
+
+// AddCloser registers a connection to be closed when the session is terminated
+func (ls *LiveSession) AddCloser(c io.Closer) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.closers = append(ls.closers, c)
+}
+
+// Terminate closes all connections of the session
+func (ls *LiveSession) Terminate(reason string) {
+	ls.Stats.SetCloseReason(reason)
+
+	ls.mu.Lock()
+	closers := ls.closers
+	ls.mu.Unlock()
+
+	for _, c := range closers {
+		c.Close()
+	}
+}
+
+type LiveSessionInfo struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	ServerID   string    `json:"server_id"`
+	ServerUser string    `json:"server_user"`
+	RemoteAddr string    `json:"remote_addr"`
+	StartedAt  time.Time `json:"started_at"`
+	Channels   int64     `json:"channels"`
+	BytesIn    int64     `json:"bytes_in"`
+	BytesOut   int64     `json:"bytes_out"`
+}
+
+// Info returns a snapshot of the session
+func (ls *LiveSession) Info() LiveSessionInfo {
+	return LiveSessionInfo{
+		ID:         ls.ID,
+		UserID:     ls.UserID,
+		ServerID:   ls.ServerID,
+		ServerUser: ls.ServerUser,
+		RemoteAddr: ls.RemoteAddr,
+		StartedAt:  ls.StartedAt,
+		Channels:   ls.Stats.Channels.Load(),
+		BytesIn:    ls.Stats.BytesIn.Load(),
+		BytesOut:   ls.Stats.BytesOut.Load(),
+	}
+}
+
+// SessionRegistry keeps track of live sessions
+type SessionRegistry struct {
+	mu       sync.RWMutex
+	sessions map[string]*LiveSession
+}
+
+func CreateSessionRegistry() *SessionRegistry {
+	return &SessionRegistry{
+		sessions: map[string]*LiveSession{},
+	}
+}
+
+// Add registers a live session
+func (r *SessionRegistry) Add(ls *LiveSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[ls.ID] = ls
+}
+
+// Remove unregisters a live session
+func (r *SessionRegistry) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, id)
+}
+
+// Get returns a live session by id, nil if not found
+func (r *SessionRegistry) Get(id string) *LiveSession {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sessions[id]
+}
+
+// List returns snapshots of all live sessions, ordered by start time
+func (r *SessionRegistry) List() (items []LiveSessionInfo) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items = []LiveSessionInfo{}
+	for _, ls := range r.sessions {
+		items = append(items, ls.Info())
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].StartedAt.Before(items[j].StartedAt)
+	})
+	return
+}
+
+// Terminate terminates a live session by id, returns false if not found
+func (r *SessionRegistry) Terminate(id string, reason string) bool {
+	ls := r.Get(id)
+	if ls == nil {
+		return false
+	}
+	ls.Terminate(reason)
+	return true
+}
+
+// TerminateUser terminates all live sessions of a user, returns the number of sessions terminated
+func (r *SessionRegistry) TerminateUser(userID string, reason string) (count int) {
+	r.mu.RLock()
+	var targets []*LiveSession
+	for _, ls := range r.sessions {
+		if ls.UserID == userID {
+			targets = append(targets, ls)
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, ls := range targets {
+		ls.Terminate(reason)
+		count++
+	}
+	return
+}
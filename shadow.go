@@ -0,0 +1,243 @@
+package bunker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	shadowWatcherBacklog = 256
+)
+
+// ShadowEvent is a terminal event delivered to watchers
+type ShadowEvent struct {
+	// "output", "resize" or "closed"
+	Type   string `json:"type"`
+	Data   []byte `json:"-"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// Shadow fans out terminal output of a live session to watchers, and optionally accepts input from them
+type Shadow struct {
+	mu       sync.Mutex
+	watchers map[*ShadowWatcher]struct{}
+	input    io.Writer
+	banner   io.Writer
+	width    int
+	height   int
+}
+
+func NewShadow() *Shadow {
+	return &Shadow{
+		watchers: map[*ShadowWatcher]struct{}{},
+		width:    recordingDefaultWidth,
+		height:   recordingDefaultHeight,
+	}
+}
+
+// Attach sets the terminal that watchers are able to write input to, banners are written to the user terminal
+func (s *Shadow) Attach(input io.Writer, banner io.Writer) (detach func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.input, s.banner = input, banner
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.input == input {
+			s.input, s.banner = nil, nil
+		}
+	}
+}
+
+// HandleRequest inspects a forwarded channel request, tracking terminal dimensions
+func (s *Shadow) HandleRequest(req *ssh.Request) {
+	switch req.Type {
+	case "pty-req":
+		var p sshPtyRequest
+		if ssh.Unmarshal(req.Payload, &p) == nil {
+			s.Resize(int(p.Columns), int(p.Rows))
+		}
+	case "window-change":
+		var p sshWindowChangeRequest
+		if ssh.Unmarshal(req.Payload, &p) == nil {
+			s.Resize(int(p.Columns), int(p.Rows))
+		}
+	}
+}
+
+// Resize updates the terminal dimensions and notifies watchers
+func (s *Shadow) Resize(width, height int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	s.width, s.height = width, height
+	banner, msgs := s.banner, s.broadcast(ShadowEvent{Type: "resize", Width: width, Height: height})
+	s.mu.Unlock()
+
+	writeBanner(banner, msgs...)
+}
+
+// Write delivers terminal output to watchers, it never fails so that it can be used with io.MultiWriter
+func (s *Shadow) Write(p []byte) (n int, err error) {
+	n = len(p)
+
+	s.mu.Lock()
+	if len(s.watchers) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	banner, msgs := s.banner, s.broadcast(ShadowEvent{Type: "output", Data: append([]byte(nil), p...)})
+	s.mu.Unlock()
+
+	writeBanner(banner, msgs...)
+	return
+}
+
+// broadcast returns banner messages of watchers dropped, to be written after unlocking
+func (s *Shadow) broadcast(evt ShadowEvent) (msgs []string) {
+	for w := range s.watchers {
+		select {
+		case w.events <- evt:
+		default:
+			// drop watchers that can not keep up
+			if msg := s.remove(w); msg != "" {
+				msgs = append(msgs, msg)
+			}
+		}
+	}
+	return
+}
+
+// remove returns the banner message, empty if the watcher is already removed
+func (s *Shadow) remove(w *ShadowWatcher) string {
+	if _, ok := s.watchers[w]; !ok {
+		return ""
+	}
+	delete(s.watchers, w)
+	close(w.events)
+	return fmt.Sprintf("%s stopped watching this session", w.name)
+}
+
+// writeBanner writes messages to the user terminal, it must not be called with mu held,
+// so that a stalled user terminal does not block watchers
+func writeBanner(banner io.Writer, msgs ...string) {
+	if banner == nil {
+		return
+	}
+	for _, msg := range msgs {
+		_, _ = banner.Write([]byte("\r\n[bunker] " + msg + "\r\n"))
+	}
+}
+
+// Watch subscribes to the terminal, interactive watchers can also write input
+func (s *Shadow) Watch(name string, interactive bool) *ShadowWatcher {
+	w := &ShadowWatcher{
+		shadow:      s,
+		name:        name,
+		interactive: interactive,
+		events:      make(chan ShadowEvent, shadowWatcherBacklog),
+	}
+
+	s.mu.Lock()
+	w.events <- ShadowEvent{Type: "resize", Width: s.width, Height: s.height}
+	s.watchers[w] = struct{}{}
+	banner := s.banner
+	s.mu.Unlock()
+
+	if interactive {
+		writeBanner(banner, fmt.Sprintf("%s joined this session with input", name))
+	} else {
+		writeBanner(banner, fmt.Sprintf("%s is watching this session", name))
+	}
+	return w
+}
+
+// Close notifies and disconnects all watchers
+func (s *Shadow) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for w := range s.watchers {
+		select {
+		case w.events <- ShadowEvent{Type: "closed"}:
+		default:
+		}
+		delete(s.watchers, w)
+		close(w.events)
+	}
+}
+
+// ShadowWatcher is a subscriber of a Shadow
+type ShadowWatcher struct {
+	shadow      *Shadow
+	name        string
+	interactive bool
+	events      chan ShadowEvent
+}
+
+// Events returns the channel of terminal events, closed when the watcher is removed
+func (w *ShadowWatcher) Events() <-chan ShadowEvent {
+	return w.events
+}
+
+// Interactive returns true if the watcher is able to write input
+func (w *ShadowWatcher) Interactive() bool {
+	w.shadow.mu.Lock()
+	defer w.shadow.mu.Unlock()
+
+	return w.interactive
+}
+
+// Join upgrades a read-only watcher to write input, returns false if already interactive or removed
+func (w *ShadowWatcher) Join() bool {
+	w.shadow.mu.Lock()
+	if _, ok := w.shadow.watchers[w]; !ok || w.interactive {
+		w.shadow.mu.Unlock()
+		return false
+	}
+	w.interactive = true
+	banner := w.shadow.banner
+	w.shadow.mu.Unlock()
+
+	writeBanner(banner, fmt.Sprintf("%s joined this session with input", w.name))
+	return true
+}
+
+// Input writes input to the terminal, only allowed for interactive watchers
+func (w *ShadowWatcher) Input(p []byte) (err error) {
+	w.shadow.mu.Lock()
+	interactive, input := w.interactive, w.shadow.input
+	w.shadow.mu.Unlock()
+
+	if !interactive {
+		return errors.New("watcher is read-only")
+	}
+
+	if input == nil {
+		return errors.New("no terminal attached")
+	}
+
+	_, err = input.Write(p)
+	return
+}
+
+// Close unsubscribes the watcher
+func (w *ShadowWatcher) Close() {
+	w.shadow.mu.Lock()
+	banner, msg := w.shadow.banner, w.shadow.remove(w)
+	w.shadow.mu.Unlock()
+
+	if msg != "" {
+		writeBanner(banner, msg)
+	}
+}
@@ -7,16 +7,22 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/binary"
 	"encoding/pem"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/yankeguo/ufx"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/ssh"
 )
 
+const (
+	sshCertExtUserID = "user_id@bunker"
+)
+
 type SSHPrivateKeyGenerator = func() (key crypto.PrivateKey, err error)
 
 var (
@@ -37,8 +43,10 @@ var (
 type Signers struct {
 	Host   []ssh.Signer
 	Client []ssh.Signer
+	CA     ssh.Signer
 
-	AuthorizedKeys string
+	AuthorizedKeys    string
+	TrustedUserCAKeys string
 }
 
 func loadOrCreateSigner(log *zap.SugaredLogger, filename string, generator SSHPrivateKeyGenerator) (sgn ssh.Signer, err error) {
@@ -108,11 +116,69 @@ func CreateSigners(log *zap.SugaredLogger, dir DataDir) (signers *Signers, err e
 
 	log.Info("\n------- Client Public Keys -------\n" + strings.TrimSpace(signers.AuthorizedKeys) + "\n----------------------------------")
 
+	if signers.CA, err = loadOrCreateSigner(log, filepath.Join(dir.String(), "ssh_ca_ed25519_key"), sshPrivateKeyGenerators["ed25519"]); err != nil {
+		return
+	}
+
+	signers.TrustedUserCAKeys = string(ssh.MarshalAuthorizedKey(signers.CA.PublicKey()))
+
+	log.Info("\n------- Trusted User CA Keys -------\n" + strings.TrimSpace(signers.TrustedUserCAKeys) + "\n------------------------------------")
+
 	return
 }
 
+// CreateUserCertificateSigner mints an ephemeral key and a short-lived user certificate signed by the CA
+func (s *Signers) CreateUserCertificateSigner(userID string, serverUser string, validity time.Duration) (sgn ssh.Signer, err error) {
+	var key crypto.PrivateKey
+	if key, err = sshPrivateKeyGenerators["ed25519"](); err != nil {
+		return
+	}
+
+	var keySigner ssh.Signer
+	if keySigner, err = ssh.NewSignerFromKey(key); err != nil {
+		return
+	}
+
+	serial := make([]byte, 8)
+	if _, err = rand.Read(serial); err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	cert := &ssh.Certificate{
+		Key:             keySigner.PublicKey(),
+		Serial:          binary.BigEndian.Uint64(serial),
+		CertType:        ssh.UserCert,
+		KeyId:           "bunker:" + userID,
+		ValidPrincipals: []string{serverUser},
+		// tolerate clock skew between bunker and servers
+		ValidAfter:  uint64(now.Add(-time.Minute).Unix()),
+		ValidBefore: uint64(now.Add(validity).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-X11-forwarding":   "",
+				"permit-agent-forwarding": "",
+				"permit-port-forwarding":  "",
+				"permit-pty":              "",
+				"permit-user-rc":          "",
+				sshCertExtUserID:          userID,
+			},
+		},
+	}
+
+	if err = cert.SignCert(rand.Reader, s.CA); err != nil {
+		return
+	}
+
+	return ssh.NewCertSigner(cert, keySigner)
+}
+
 func InstallSignersToRouter(ur ufx.Router, signers *Signers) {
 	ur.HandleFunc("/backend/authorized_keys", func(c ufx.Context) {
 		c.Text(signers.AuthorizedKeys)
 	})
+	ur.HandleFunc("/backend/trusted_user_ca_keys", func(c ufx.Context) {
+		c.Text(signers.TrustedUserCAKeys)
+	})
 }
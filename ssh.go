@@ -1,44 +1,77 @@
 package bunker
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/git-lfs/wildmatch"
 	"github.com/yankeguo/bunker/model"
 	"github.com/yankeguo/bunker/model/dao"
 	"github.com/yankeguo/ufx"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/ssh"
+	"gorm.io/gen"
+	"gorm.io/gen/field"
 	"gorm.io/gorm"
 )
 
+const (
+	HostKeyPolicyTOFU   = "tofu"
+	HostKeyPolicyStrict = "strict"
+)
+
+const (
+	ClientAuthCertificate = "certificate"
+	ClientAuthKey         = "key"
+	ClientAuthBoth        = "both"
+)
+
 const (
 	sshExtKeyUserID        = "bunker.user_id"
+	sshExtKeyKeyID         = "bunker.key_id"
 	sshExtKeyServerID      = "bunker.server_id"
 	sshExtKeyServerUser    = "bunker.server_user"
 	sshExtKeyServerAddress = "bunker.server_address"
+	sshExtKeyMFA           = "bunker.mfa"
+)
+
+var (
+	errUnknownKey       = errors.New("unknown key")
+	errServerRequireMFA = errors.New("server requires mfa, enable totp first")
 )
 
 type SSHServer struct {
-	dataDir  string
-	listen   string
-	db       *gorm.DB
-	signers  *Signers
-	loggers  *zap.SugaredLogger
-	listener *net.TCPListener
+	dataDir             string
+	listen              string
+	hostKeyPolicy       string
+	clientAuth          string
+	certificateValidity time.Duration
+	db                  *gorm.DB
+	signers             *Signers
+	sessions            *SessionRegistry
+	auditor             *Auditor
+	policies            *PolicyEngine
+	loggers             *zap.SugaredLogger
+	listener            *net.TCPListener
 }
 
 type sshServerParams struct {
-	Listen string `json:"listen" default:":8022" validate:"required"`
+	Listen        string `json:"listen" default:":8022" validate:"required"`
+	HostKeyPolicy string `json:"host_key_policy" default:"tofu" validate:"oneof=tofu strict"`
+	ClientAuth    string `json:"client_auth" default:"both" validate:"oneof=certificate key both"`
+	// validity of user certificates in seconds
+	CertificateValidity int `json:"certificate_validity" default:"300" validate:"min=30"`
 }
 
 type SSHServerOptions struct {
@@ -49,6 +82,9 @@ type SSHServerOptions struct {
 	DataDir   DataDir
 	DB        *gorm.DB
 	Signers   *Signers
+	Sessions  *SessionRegistry
+	Auditor   *Auditor
+	Policies  *PolicyEngine
 	Logger    *zap.SugaredLogger
 }
 
@@ -60,11 +96,21 @@ func CreateSSHServer(opts SSHServerOptions) (s *SSHServer, err error) {
 	}
 
 	s = &SSHServer{
-		dataDir: opts.DataDir.String(),
-		listen:  p.Listen,
-		signers: opts.Signers,
-		loggers: opts.Logger,
-		db:      opts.DB,
+		dataDir:             opts.DataDir.String(),
+		listen:              p.Listen,
+		hostKeyPolicy:       p.HostKeyPolicy,
+		clientAuth:          p.ClientAuth,
+		certificateValidity: time.Duration(p.CertificateValidity) * time.Second,
+		signers:             opts.Signers,
+		sessions:            opts.Sessions,
+		auditor:             opts.Auditor,
+		policies:            opts.Policies,
+		loggers:             opts.Logger,
+		db:                  opts.DB,
+	}
+
+	if s.clientAuth == ClientAuthBoth {
+		s.loggers.Warn("ssh_server.client_auth is both, static client keys remain trusted by servers, set it to certificate once certificates are rolled out")
 	}
 
 	if opts.Lifecycle != nil {
@@ -100,14 +146,53 @@ func (s *SSHServer) AuthLogCallback(conn ssh.ConnMetadata, method string, err er
 	).Info("ssh auth")
 }
 
+// auditAuth records the outcome of a ssh authentication step
+func (s *SSHServer) auditAuth(conn ssh.ConnMetadata, action string, userID string, after any, err error) {
+	event := &model.AuditEvent{
+		Actor:    userID,
+		Action:   action,
+		Target:   conn.User(),
+		After:    auditState(after),
+		SourceIP: addrIP(conn.RemoteAddr().String()),
+	}
+
+	var partial *ssh.PartialSuccessError
+	if errors.As(err, &partial) {
+		event.Reason = "totp required"
+	} else if err != nil {
+		event.Result = model.AuditResultFailure
+		event.Reason = auditReason(err)
+	}
+
+	s.auditor.Record(event)
+}
+
 func (s *SSHServer) PublicKeyCallback(conn ssh.ConnMetadata, _key ssh.PublicKey) (perm *ssh.Permissions, err error) {
 	db := dao.Use(s.db)
 
+	fingerprint := ssh.FingerprintSHA256(_key)
+
 	// find key and user
 	var key *model.Key
-	if key, err = db.Key.Where(db.Key.ID.Eq(
-		ssh.FingerprintSHA256(_key),
-	)).Preload(db.Key.User).First(); err != nil {
+
+	defer func() {
+		observeSSHAuth("publickey", err)
+
+		if key == nil {
+			if errors.Is(err, errUnknownKey) {
+				err = fmt.Errorf("%w %s", err, fingerprint)
+			}
+			s.auditAuth(conn, "ssh.auth.publickey", "", nil, err)
+		} else {
+			s.auditAuth(conn, "ssh.auth.publickey", key.UserID, map[string]any{"key_id": key.ID}, err)
+		}
+	}()
+
+	if key, err = db.Key.Where(db.Key.ID.Eq(fingerprint)).Preload(db.Key.User).First(); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errUnknownKey
+		}
+		key = nil
 		return nil, err
 	}
 
@@ -116,63 +201,129 @@ func (s *SSHServer) PublicKeyCallback(conn ssh.ConnMetadata, _key ssh.PublicKey)
 		return
 	}
 
-	if key.User.IsBlocked {
-		err = errors.New("user is blocked")
+	var serverUser, serverID string
+	if serverUser, serverID, err = parseSSHUser(conn.User()); err != nil {
 		return
 	}
 
-	// find server
-	splits := strings.Split(conn.User(), "@")
-	if len(splits) != 2 {
-		err = errors.New("invalid user format, should be server_user@server_id")
-		return
+	// no server specified, server will be picked interactively
+	if serverID == "" {
+		if serverUser != key.User.ID {
+			err = errors.New("key is not associated with user " + serverUser)
+			return
+		}
+		if key.User.IsBlocked {
+			err = errUserBlocked
+			return
+		}
+		return s.requireMFA(&key.User, nil, &ssh.Permissions{
+			Extensions: map[string]string{
+				sshExtKeyUserID: key.User.ID,
+				sshExtKeyKeyID:  key.ID,
+			},
+		})
 	}
 
-	var (
-		serverUser = splits[0]
-		serverID   = splits[1]
-	)
-
 	var server *model.Server
-	if server, err = db.Server.Where(db.Server.ID.Eq(serverID)).First(); err != nil {
+	if server, err = AuthorizeServerAccess(s.db, &key.User, serverUser, serverID); err != nil {
 		return
 	}
 
-	// find grants
-	var grants = []*model.Grant{}
-	if grants, err = db.Grant.Where(db.Grant.UserID.Eq(key.User.ID)).Find(); err != nil {
+	if err = s.policies.Authorize(PolicyInput{
+		User:       &key.User,
+		Server:     server,
+		ServerUser: serverUser,
+		SourceIP:   addrIP(conn.RemoteAddr().String()),
+		Time:       time.Now(),
+	}); err != nil {
 		return
 	}
 
-	var granted bool
+	return s.requireMFA(&key.User, server, &ssh.Permissions{
+		Extensions: map[string]string{
+			sshExtKeyUserID:        key.User.ID,
+			sshExtKeyKeyID:         key.ID,
+			sshExtKeyServerID:      server.ID,
+			sshExtKeyServerAddress: server.Address,
+			sshExtKeyServerUser:    serverUser,
+		},
+	})
+}
 
-	// check if user is granted
-	for _, grant := range grants {
-		var (
-			mServerUser = wildmatch.NewWildmatch(grant.ServerUser, wildmatch.Basename, wildmatch.CaseFold)
-			mServerID   = wildmatch.NewWildmatch(grant.ServerID, wildmatch.Basename, wildmatch.CaseFold)
-		)
+// parseSSHUser splits ssh user of format server_user@server_id, server_id is empty if only user is given
+func parseSSHUser(s string) (serverUser string, serverID string, err error) {
+	splits := strings.Split(s, "@")
 
-		if mServerUser.Match(serverUser) && mServerID.Match(serverID) {
-			granted = true
-			break
-		}
+	switch {
+	case len(splits) == 1:
+		serverUser = splits[0]
+	case len(splits) == 2 && splits[1] != "":
+		serverUser, serverID = splits[0], splits[1]
+	default:
+		err = errors.New("invalid user format, should be server_user@server_id or user")
 	}
+	return
+}
 
-	if !granted {
-		err = errors.New("no grant found")
+// sshMFARequired checks if the user must pass TOTP challenge, fails if the server requires MFA but the user has no TOTP
+func sshMFARequired(user *model.User, server *model.Server) (required bool, err error) {
+	if user.TOTPEnabled {
+		required = true
 		return
 	}
+	if server != nil && server.RequireMFA {
+		err = errServerRequireMFA
+	}
+	return
+}
 
-	perm = &ssh.Permissions{
-		Extensions: map[string]string{
-			sshExtKeyUserID:        key.User.ID,
-			sshExtKeyServerID:      server.ID,
-			sshExtKeyServerAddress: server.Address,
-			sshExtKeyServerUser:    serverUser,
+// requireMFA asks the client to continue with a keyboard-interactive TOTP challenge,
+// if the user enabled TOTP or the server requires MFA
+func (s *SSHServer) requireMFA(user *model.User, server *model.Server, perm *ssh.Permissions) (*ssh.Permissions, error) {
+	if required, err := sshMFARequired(user, server); err != nil {
+		return nil, err
+	} else if !required {
+		return perm, nil
+	}
+
+	return nil, &ssh.PartialSuccessError{
+		Next: ssh.ServerAuthCallbacks{
+			KeyboardInteractiveCallback: func(conn ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (_ *ssh.Permissions, err error) {
+				defer func() {
+					observeSSHAuth("keyboard-interactive", err)
+					s.auditAuth(conn, "ssh.auth.totp", perm.Extensions[sshExtKeyUserID], nil, err)
+				}()
+
+				db := dao.Use(s.db)
+
+				// reload user for latest TOTP state
+				var user *model.User
+				if user, err = db.User.Where(db.User.ID.Eq(perm.Extensions[sshExtKeyUserID])).First(); err != nil {
+					return
+				}
+				if !user.TOTPEnabled {
+					err = errors.New("totp not enabled")
+					return
+				}
+
+				var answers []string
+				if answers, err = challenge("", "bunker: two-factor authentication", []string{"TOTP or recovery code: "}, []bool{false}); err != nil {
+					return
+				}
+				if len(answers) != 1 {
+					err = errTOTPInvalid
+					return
+				}
+
+				if err = VerifyUserMFA(s.db, user, answers[0]); err != nil {
+					return
+				}
+
+				perm.Extensions[sshExtKeyMFA] = "totp"
+				return perm, nil
+			},
 		},
 	}
-	return
 }
 
 func (s *SSHServer) BannerCallback(conn ssh.ConnMetadata) string {
@@ -201,6 +352,9 @@ func (s *SSHServer) createServerConfig() *ssh.ServerConfig {
 func (s *SSHServer) HandleServerConn(conn net.Conn) {
 	defer conn.Close()
 
+	metricSSHConnections.Inc()
+	defer metricSSHConnections.Dec()
+
 	var err error
 
 	var (
@@ -215,31 +369,60 @@ func (s *SSHServer) HandleServerConn(conn net.Conn) {
 	defer userConn.Close()
 
 	var (
+		userID        = userConn.Permissions.Extensions[sshExtKeyUserID]
 		serverUser    = userConn.Permissions.Extensions[sshExtKeyServerUser]
-		serverAddress = userConn.Permissions.Extensions[sshExtKeyServerAddress]
+		serverAddress = serverDialAddress(userConn.Permissions.Extensions[sshExtKeyServerAddress])
+		serverID      = userConn.Permissions.Extensions[sshExtKeyServerID]
+		sessionID     = hex.EncodeToString(userConn.SessionID())
+		picked        *PickedChannel
 	)
 
-	if _, port, _ := net.SplitHostPort(serverAddress); port == "" {
-		serverAddress = net.JoinHostPort(serverAddress, "22")
+	if serverID == "" {
+		if picked, err = s.PickServer(userID, addrIP(conn.RemoteAddr().String()), userConn.Permissions.Extensions[sshExtKeyMFA] != "", chUserNewChannel, chUserRequest); err != nil {
+			s.loggers.With(
+				"remote_addr", conn.RemoteAddr().String(),
+				"user_id", userID,
+				"session_id", sessionID,
+				"error", err,
+			).Info("ssh server picker")
+			return
+		}
+		serverUser = picked.ServerUser
+		serverAddress = serverDialAddress(picked.Server.Address)
+		serverID = picked.Server.ID
 	}
 
 	log := s.loggers.With(
 		"remote_addr", conn.RemoteAddr().String(),
 		"server_user", serverUser,
 		"server_address", serverAddress,
-		"server_id", userConn.Permissions.Extensions[sshExtKeyServerID],
-		"session_id", hex.EncodeToString(userConn.SessionID()),
+		"server_id", serverID,
+		"session_id", sessionID,
 	)
 
+	live, end := s.BeginSession(log, &model.Session{
+		ID:         sessionID,
+		UserID:     userID,
+		KeyID:      userConn.Permissions.Extensions[sshExtKeyKeyID],
+		ServerID:   serverID,
+		ServerUser: serverUser,
+		RemoteAddr: conn.RemoteAddr().String(),
+	})
+	defer end()
+
+	live.AddCloser(userConn)
+
+	stats := live.Stats
+
 	var client *ssh.Client
-	if client, err = ssh.Dial("tcp", serverAddress, &ssh.ClientConfig{
-		User: serverUser,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(s.signers.Client...),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}); err != nil {
+	if client, err = s.DialServer(live.UserID, serverID, serverUser); err != nil {
 		log.With("error", err).Error("ssh dial")
+		stats.SetCloseReason("dial failed: " + err.Error())
+		if picked != nil {
+			picked.Channel.Stderr().Write([]byte("bunker: " + err.Error() + "\r\n"))
+			picked.Channel.Close()
+			go ssh.DiscardRequests(picked.Requests)
+		}
 		go func() {
 			for nc := range chUserNewChannel {
 				//discard all new channels
@@ -258,9 +441,233 @@ func (s *SSHServer) HandleServerConn(conn net.Conn) {
 	}
 	defer client.Close()
 
+	live.AddCloser(client)
+
 	log.Info("ssh connection established")
 
-	PipeSSH(log, client, userConn, chUserNewChannel, chUserRequest)
+	go func() {
+		client.Wait()
+		stats.SetCloseReason("target disconnected")
+		userConn.Close()
+	}()
+
+	wg := &sync.WaitGroup{}
+
+	// pipe the channel used for picking server
+	if picked != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			log := log.With("channel_type", "session")
+
+			targetChannel, chTargetRequest, err := client.OpenChannel("session", nil)
+			if err != nil {
+				log.With("error", err).Error("ssh open target channel")
+				picked.Channel.Stderr().Write([]byte("bunker: " + err.Error() + "\r\n"))
+				picked.Channel.Close()
+				go ssh.DiscardRequests(picked.Requests)
+				return
+			}
+			defer log.Info("channel end")
+			defer targetChannel.Close()
+
+			PipeSSHChannel(log, s.RecordingDir(), live, "session", picked.Channel, picked.Requests, targetChannel, chTargetRequest, picked.Replay)
+		}()
+	}
+
+	PipeSSH(log, s.RecordingDir(), live, client, userConn, chUserNewChannel, chUserRequest)
+
+	wg.Wait()
+
+	stats.SetCloseReason("user disconnected")
+}
+
+// RecordingDir returns the directory of session recordings
+func (s *SSHServer) RecordingDir() string {
+	return filepath.Join(s.dataDir, "recordings")
+}
+
+// BeginSession persists and registers a live session, the returned function finalizes it
+func (s *SSHServer) BeginSession(log *zap.SugaredLogger, session *model.Session) (live *LiveSession, end func()) {
+	session.StartedAt = time.Now()
+
+	if err := dao.Use(s.db).Session.Create(session); err != nil {
+		log.With("error", err).Error("ssh session create")
+	}
+
+	live = &LiveSession{
+		ID:         session.ID,
+		UserID:     session.UserID,
+		ServerID:   session.ServerID,
+		ServerUser: session.ServerUser,
+		RemoteAddr: session.RemoteAddr,
+		StartedAt:  session.StartedAt,
+		Stats:      &SessionStats{},
+		Shadow:     NewShadow(),
+	}
+
+	s.sessions.Add(live)
+
+	s.auditor.Record(&model.AuditEvent{
+		Actor:    session.UserID,
+		Action:   "session.start",
+		Target:   session.ServerUser + "@" + session.ServerID,
+		After:    auditState(session),
+		SourceIP: addrIP(session.RemoteAddr),
+	})
+
+	end = func() {
+		live.Shadow.Close()
+		s.sessions.Remove(live.ID)
+		s.finishSession(log, session, live.Stats)
+	}
+	return
+}
+
+func serverDialAddress(address string) string {
+	if _, port, _ := net.SplitHostPort(address); port == "" {
+		return net.JoinHostPort(address, "22")
+	}
+	return address
+}
+
+// DialServer connects to the target server on behalf of the user, verifying the host key against the pinned one
+func (s *SSHServer) DialServer(userID string, serverID string, serverUser string) (client *ssh.Client, err error) {
+	db := dao.Use(s.db)
+
+	var server *model.Server
+	if server, err = db.Server.Where(db.Server.ID.Eq(serverID)).First(); err != nil {
+		return
+	}
+
+	var signers []ssh.Signer
+
+	if s.clientAuth == ClientAuthCertificate || s.clientAuth == ClientAuthBoth {
+		var sgn ssh.Signer
+		if sgn, err = s.signers.CreateUserCertificateSigner(userID, serverUser, s.certificateValidity); err != nil {
+			return
+		}
+		signers = append(signers, sgn)
+	}
+
+	if s.clientAuth == ClientAuthKey || s.clientAuth == ClientAuthBoth {
+		signers = append(signers, s.signers.Client...)
+	}
+
+	cfg := &ssh.ClientConfig{
+		User: serverUser,
+		Auth: []ssh.AuthMethod{
+			ssh.PublicKeys(signers...),
+		},
+	}
+
+	if server.HostKey == "" {
+		if s.hostKeyPolicy == HostKeyPolicyStrict {
+			err = fmt.Errorf("host key of server %s is not pinned", serverID)
+			return
+		}
+		cfg.HostKeyCallback = s.trustHostKeyOnFirstUse(serverID)
+	} else {
+		var pinned ssh.PublicKey
+		if pinned, _, _, _, err = ssh.ParseAuthorizedKey([]byte(server.HostKey)); err != nil {
+			err = fmt.Errorf("invalid pinned host key of server %s: %w", serverID, err)
+			return
+		}
+		cfg.HostKeyAlgorithms = hostKeyAlgorithmsForKey(pinned)
+		cfg.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return checkHostKey(serverID, pinned, key)
+		}
+	}
+
+	start := time.Now()
+
+	client, err = ssh.Dial("tcp", serverDialAddress(server.Address), cfg)
+
+	metricSSHDialDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		metricSSHDialFailures.Inc()
+	}
+	return
+}
+
+func (s *SSHServer) trustHostKeyOnFirstUse(serverID string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) (err error) {
+		db := dao.Use(s.db)
+
+		hostKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+
+		var res gen.ResultInfo
+		if res, err = db.Server.Where(
+			db.Server.ID.Eq(serverID),
+			db.Server.HostKey.Eq(""),
+		).UpdateColumnSimple(db.Server.HostKey.Value(hostKey)); err != nil {
+			return
+		}
+
+		if res.RowsAffected == 0 {
+			// pinned concurrently by another connection
+			var server *model.Server
+			if server, err = db.Server.Where(db.Server.ID.Eq(serverID)).First(); err != nil {
+				return
+			}
+			var pinned ssh.PublicKey
+			if pinned, _, _, _, err = ssh.ParseAuthorizedKey([]byte(server.HostKey)); err != nil {
+				return
+			}
+			return checkHostKey(serverID, pinned, key)
+		}
+
+		s.loggers.With(
+			"server_id", serverID,
+			"fingerprint", ssh.FingerprintSHA256(key),
+		).Info("host key pinned on first use")
+		return
+	}
+}
+
+func checkHostKey(serverID string, pinned ssh.PublicKey, key ssh.PublicKey) error {
+	if bytes.Equal(pinned.Marshal(), key.Marshal()) {
+		return nil
+	}
+	return fmt.Errorf(
+		"host key mismatch for server %s, expected %s, got %s",
+		serverID,
+		ssh.FingerprintSHA256(pinned),
+		ssh.FingerprintSHA256(key),
+	)
+}
+
+func hostKeyAlgorithmsForKey(key ssh.PublicKey) []string {
+	if key.Type() == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{key.Type()}
+}
+
+func (s *SSHServer) finishSession(log *zap.SugaredLogger, session *model.Session, stats *SessionStats) {
+	db := dao.Use(s.db)
+
+	assigns := []field.AssignExpr{
+		db.Session.EndedAt.Value(time.Now()),
+		db.Session.BytesIn.Value(stats.BytesIn.Load()),
+		db.Session.BytesOut.Value(stats.BytesOut.Load()),
+		db.Session.CloseReason.Value(stats.CloseReason()),
+	}
+
+	if exitStatus := stats.ExitStatus(); exitStatus != nil {
+		assigns = append(assigns, db.Session.ExitStatus.Value(*exitStatus))
+	}
+
+	if _, err := db.Session.Where(db.Session.ID.Eq(session.ID)).UpdateColumnSimple(assigns...); err != nil {
+		log.With("error", err).Error("ssh session finish")
+	}
+
+	log.With(
+		"bytes_in", stats.BytesIn.Load(),
+		"bytes_out", stats.BytesOut.Load(),
+		"close_reason", stats.CloseReason(),
+	).Info("ssh connection closed")
 }
 
 func (s *SSHServer) ListenAndServe() (err error) {
@@ -297,7 +704,7 @@ func (s *SSHServer) Shutdown(ctx context.Context) (err error) {
 	return
 }
 
-func PipeSSH(log *zap.SugaredLogger, target *ssh.Client, userConn *ssh.ServerConn, chUserNewChannel <-chan ssh.NewChannel, chUserRequest <-chan *ssh.Request) {
+func PipeSSH(log *zap.SugaredLogger, recordingDir string, live *LiveSession, target *ssh.Client, userConn *ssh.ServerConn, chUserNewChannel <-chan ssh.NewChannel, chUserRequest <-chan *ssh.Request) {
 	// handle user request for new channel
 	handleUserNewChannel := func(wg *sync.WaitGroup, userNewChannel ssh.NewChannel) {
 		defer wg.Done()
@@ -324,55 +731,7 @@ func PipeSSH(log *zap.SugaredLogger, target *ssh.Client, userConn *ssh.ServerCon
 			return
 		}
 
-		wg1 := &sync.WaitGroup{}
-
-		wg1.Add(1)
-		go func() {
-			defer wg1.Done()
-			defer log.Info("channel pipe end: from target")
-			defer userChannel.Close()
-			io.Copy(userChannel, targetChannel)
-		}()
-
-		wg1.Add(1)
-		go func() {
-			defer wg1.Done()
-			defer log.Info("channel pipe end: from user")
-			defer targetChannel.Close()
-			io.Copy(targetChannel, userChannel)
-		}()
-
-		wg1.Add(1)
-		go func() {
-			defer wg1.Done()
-			defer log.Info("channel request end: from target")
-			for targetRequest := range chTargetRequest {
-				ok, err2 := userChannel.SendRequest(targetRequest.Type, targetRequest.WantReply, targetRequest.Payload)
-				if targetRequest.WantReply {
-					targetRequest.Reply(ok, nil)
-				}
-				if err2 != nil {
-					log.With("error", err2).Error("ssh send target request")
-				}
-			}
-		}()
-
-		wg1.Add(1)
-		go func() {
-			defer wg1.Done()
-			defer log.Info("channel request end: from user")
-			for userRequest := range chUserRequest {
-				ok, err2 := targetChannel.SendRequest(userRequest.Type, userRequest.WantReply, userRequest.Payload)
-				if userRequest.WantReply {
-					userRequest.Reply(ok, nil)
-				}
-				if err2 != nil {
-					log.With("error", err2).Error("ssh send user request")
-				}
-			}
-		}()
-
-		wg1.Wait()
+		PipeSSHChannel(log, recordingDir, live, userNewChannel.ChannelType(), userChannel, chUserRequest, targetChannel, chTargetRequest, nil)
 	}
 
 	handleUserRequest := func(wg *sync.WaitGroup, userRequest *ssh.Request) {
@@ -419,3 +778,122 @@ func PipeSSH(log *zap.SugaredLogger, target *ssh.Client, userConn *ssh.ServerCon
 
 	wg.Wait()
 }
+
+// PipeSSHChannel pipes data and requests between an accepted user channel and an opened target channel,
+// replay contains user requests already replied by bunker, which are sent to the target before piping
+func PipeSSHChannel(log *zap.SugaredLogger, recordingDir string, live *LiveSession, channelType string, userChannel ssh.Channel, chUserRequest <-chan *ssh.Request, targetChannel ssh.Channel, chTargetRequest <-chan *ssh.Request, replay []*ssh.Request) {
+	stats := live.Stats
+
+	stats.Channels.Add(1)
+	defer stats.Channels.Add(-1)
+
+	metricSSHChannels.Inc()
+	defer metricSSHChannels.Dec()
+
+	// record session channel
+	var (
+		wOutput   io.Writer = userChannel
+		onRequest           = func(*ssh.Request) {}
+	)
+
+	if channelType == "session" {
+		rec := NewRecording(filepath.Join(
+			recordingDir,
+			live.ID,
+			strconv.FormatInt(live.NextChannelIndex(), 10)+".cast",
+		))
+		defer func() {
+			if err := rec.Close(); err != nil {
+				log.With("error", err).Error("ssh recording")
+			}
+		}()
+		wOutput = io.MultiWriter(userChannel, rec, live.Shadow)
+
+		// expose terminal to shadowing watchers
+		var detach func()
+		defer func() {
+			if detach != nil {
+				detach()
+			}
+		}()
+
+		// only requests accepted by the target, a rejected shell or pty-req neither records nor attaches
+		onRequest = func(req *ssh.Request) {
+			rec.HandleRequest(req)
+			live.Shadow.HandleRequest(req)
+			if req.Type == "pty-req" && detach == nil {
+				detach = live.Shadow.Attach(countingWriter{w: targetChannel, n: &stats.BytesIn, m: metricBytesIn}, userChannel)
+			}
+		}
+	}
+
+	// replay requests already handled by bunker
+	for _, req := range replay {
+		ok, err := targetChannel.SendRequest(req.Type, req.WantReply, req.Payload)
+		if err != nil || (req.WantReply && !ok) {
+			log.With("error", err, "request_type", req.Type).Error("ssh replay user request")
+			userChannel.Stderr().Write([]byte("bunker: server rejected request " + req.Type + "\r\n"))
+			userChannel.Close()
+			go ssh.DiscardRequests(chUserRequest)
+			go ssh.DiscardRequests(chTargetRequest)
+			return
+		}
+		onRequest(req)
+	}
+
+	wg1 := &sync.WaitGroup{}
+
+	wg1.Add(1)
+	go func() {
+		defer wg1.Done()
+		defer log.Info("channel pipe end: from target")
+		defer userChannel.Close()
+		io.Copy(countingWriter{w: wOutput, n: &stats.BytesOut, m: metricBytesOut}, targetChannel)
+	}()
+
+	wg1.Add(1)
+	go func() {
+		defer wg1.Done()
+		defer log.Info("channel pipe end: from user")
+		defer targetChannel.Close()
+		io.Copy(countingWriter{w: targetChannel, n: &stats.BytesIn, m: metricBytesIn}, userChannel)
+	}()
+
+	wg1.Add(1)
+	go func() {
+		defer wg1.Done()
+		defer log.Info("channel request end: from target")
+		for targetRequest := range chTargetRequest {
+			if targetRequest.Type == "exit-status" && len(targetRequest.Payload) >= 4 {
+				stats.SetExitStatus(int(binary.BigEndian.Uint32(targetRequest.Payload)))
+			}
+			ok, err2 := userChannel.SendRequest(targetRequest.Type, targetRequest.WantReply, targetRequest.Payload)
+			if targetRequest.WantReply {
+				targetRequest.Reply(ok, nil)
+			}
+			if err2 != nil {
+				log.With("error", err2).Error("ssh send target request")
+			}
+		}
+	}()
+
+	wg1.Add(1)
+	go func() {
+		defer wg1.Done()
+		defer log.Info("channel request end: from user")
+		for userRequest := range chUserRequest {
+			ok, err2 := targetChannel.SendRequest(userRequest.Type, userRequest.WantReply, userRequest.Payload)
+			if err2 == nil && (ok || !userRequest.WantReply) {
+				onRequest(userRequest)
+			}
+			if userRequest.WantReply {
+				userRequest.Reply(ok, nil)
+			}
+			if err2 != nil {
+				log.With("error", err2).Error("ssh send user request")
+			}
+		}
+	}()
+
+	wg1.Wait()
+}
@@ -0,0 +1,163 @@
+package bunker
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	webTerminalTerm = "xterm-256color"
+)
+
+// WebTerminalMessage is a control message exchanged as websocket text message
+type WebTerminalMessage struct {
+	// "resize", "error" or "closed", and "join" of session shadowing
+	Type    string `json:"type"`
+	Width   int    `json:"width,omitempty"`
+	Height  int    `json:"height,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// websocketWriter writes binary messages to a websocket connection, safe for concurrent use
+type websocketWriter struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (w *websocketWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err = w.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return
+	}
+	n = len(p)
+	return
+}
+
+// WriteJSON writes a control message as text message
+func (w *websocketWriter) WriteJSON(v any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.conn.WriteJSON(v)
+}
+
+// PipeWebTerminal opens a shell with pty on the target, and pipes it to the websocket connection,
+// terminal data is exchanged as binary messages, control messages as text messages
+func PipeWebTerminal(log *zap.SugaredLogger, recordingDir string, live *LiveSession, target *ssh.Client, ws *websocketWriter, width, height int) (err error) {
+	stats := live.Stats
+
+	var (
+		channel   ssh.Channel
+		chRequest <-chan *ssh.Request
+	)
+	if channel, chRequest, err = target.OpenChannel("session", nil); err != nil {
+		return
+	}
+	defer channel.Close()
+
+	stats.Channels.Add(1)
+	defer stats.Channels.Add(-1)
+
+	metricSSHChannels.Inc()
+	defer metricSSHChannels.Dec()
+
+	rec := NewRecording(filepath.Join(recordingDir, live.ID, strconv.FormatInt(live.NextChannelIndex(), 10)+".cast"))
+	defer func() {
+		if err := rec.Close(); err != nil {
+			log.With("error", err).Error("web terminal recording")
+		}
+	}()
+
+	rec.SetTerminal(webTerminalTerm, width, height)
+	live.Shadow.Resize(width, height)
+
+	wInput := countingWriter{w: channel, n: &stats.BytesIn, m: metricBytesIn}
+
+	detach := live.Shadow.Attach(wInput, ws)
+	defer detach()
+
+	go func() {
+		for req := range chRequest {
+			if req.Type == "exit-status" && len(req.Payload) >= 4 {
+				stats.SetExitStatus(int(binary.BigEndian.Uint32(req.Payload)))
+			}
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}()
+
+	var ok bool
+	if ok, err = channel.SendRequest("pty-req", true, ssh.Marshal(sshPtyRequest{
+		Term:     webTerminalTerm,
+		Columns:  uint32(width),
+		Rows:     uint32(height),
+		Modelist: string([]byte{0}),
+	})); err != nil {
+		return
+	} else if !ok {
+		err = errors.New("pty request rejected")
+		return
+	}
+
+	if ok, err = channel.SendRequest("shell", true, nil); err != nil {
+		return
+	} else if !ok {
+		err = errors.New("shell request rejected")
+		return
+	}
+
+	rec.Start("")
+
+	go func() {
+		defer ws.conn.Close()
+		defer log.Info("web terminal pipe end: from target")
+		io.Copy(countingWriter{w: io.MultiWriter(ws, rec, live.Shadow), n: &stats.BytesOut, m: metricBytesOut}, channel)
+		ws.WriteJSON(WebTerminalMessage{Type: "closed"})
+	}()
+
+	defer log.Info("web terminal pipe end: from user")
+
+	for {
+		var (
+			kind int
+			buf  []byte
+		)
+		if kind, buf, err = ws.conn.ReadMessage(); err != nil {
+			err = nil
+			return
+		}
+
+		if kind == websocket.BinaryMessage {
+			if _, err = wInput.Write(buf); err != nil {
+				return
+			}
+			continue
+		}
+
+		var msg WebTerminalMessage
+		if json.Unmarshal(buf, &msg) != nil {
+			continue
+		}
+
+		if msg.Type == "resize" && msg.Width > 0 && msg.Height > 0 {
+			channel.SendRequest("window-change", false, ssh.Marshal(sshWindowChangeRequest{
+				Columns: uint32(msg.Width),
+				Rows:    uint32(msg.Height),
+			}))
+			rec.Resize(msg.Width, msg.Height)
+			live.Shadow.Resize(msg.Width, msg.Height)
+		}
+	}
+}
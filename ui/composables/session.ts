@@ -0,0 +1,11 @@
+export const useActiveSessions = () => {
+  return useAsyncData<{ sessions: BLiveSession[] }>(
+    "active-sessions",
+    () => $fetch("/backend/sessions/active"),
+    {
+      default() {
+        return { sessions: [] };
+      },
+    }
+  );
+};
@@ -21,7 +21,10 @@ const en = {
     password: 'Password',
     submit: 'Submit',
     sign_out: 'Sign Out',
-    sign_in: 'Sign In'
+    sign_in: 'Sign In',
+    cancel: 'Cancel',
+    or: 'or',
+    sign_in_sso: 'Sign In with SSO'
   },
   dashboard: {
     command_example: 'Command Example',
@@ -34,6 +37,8 @@ const en = {
     input_server_id: 'Input server name here',
     input_server_address: 'Input server address here',
     view_authorized_keys: 'View Authorized Keys',
+    require_mfa: 'Require Two-Factor',
+    intro_require_mfa: 'Only users signed in with two-factor authentication can connect',
     intro_authorized_keys: 'To allow Bunker to relay SSH connections to this server, please add the following public key to the server user\'s <code>$HOME/.ssh/authorized_keys</code> file'
   },
   users: {
@@ -50,6 +55,11 @@ const en = {
     title: 'Grants',
     add_grant: 'Add Grant',
     intro_asterisk: 'The asterisk (*) is a wildcard that matches any server user or server name',
+    duration_hours: 'Duration (Hours)',
+    intro_duration: 'Leave empty for the default duration, 8 hours unless configured',
+    permanent: 'Permanent',
+    expires_at: 'Expires At',
+    never: 'Never',
   },
   ssh_keys: {
     title: 'SSH Keys',
@@ -69,6 +79,51 @@ const en = {
     repeat_password: 'Repeat Password',
     input_repeat_password: 'Input repeat password here',
   },
+  mfa: {
+    title: 'Two-Factor Authentication',
+    enabled: 'Enabled',
+    not_enabled: 'Not Enabled',
+    enroll: 'Set Up',
+    intro_enroll: 'Protect your account with a time-based one-time password (TOTP) from an authenticator app',
+    intro_secret: 'Add the secret or the URI below to your authenticator app, then input the code it shows',
+    secret: 'Secret',
+    uri: 'URI',
+    code: 'Code',
+    input_code: 'Input code or recovery code here',
+    input_totp_code: 'Input code from authenticator app here',
+    enable: 'Verify and Enable',
+    disable: 'Disable',
+    confirm_disable: 'Are you sure to disable two-factor authentication?',
+    regenerate_recovery_codes: 'Regenerate Recovery Codes',
+    intro_recovery_codes: 'Save these recovery codes somewhere safe, each can be used once in place of a code, they will not be shown again',
+    intro_challenge: 'Input the code from your authenticator app, or a recovery code',
+    verify: 'Verify',
+  },
+  terminal: {
+    title: 'Terminal',
+    open: 'Terminal',
+    intro: 'Input server user and server name, then connect to open a shell in browser',
+    connect: 'Connect',
+    disconnect: 'Disconnect',
+    closed: 'Connection closed',
+  },
+  sessions: {
+    title: 'Live Sessions',
+    intro: 'Sessions connected through Bunker right now, admins can watch a session, join it with input, or terminate it',
+    refresh: 'Refresh',
+    target: 'Target',
+    remote_addr: 'Remote Address',
+    started_at: 'Started At',
+    shadow: 'Shadow',
+    terminate: 'Terminate',
+    confirm_terminate: 'Are you sure to terminate this session?',
+    read_only: 'Read Only',
+    joined: 'Joined',
+    closed: 'Session Closed',
+    join: 'Join',
+    leave: 'Back to Read Only',
+    confirm_join: 'Your keystrokes will be sent to the session, and the user will be notified, continue?',
+  },
   lastwill: "Alive?",
   pronouns: "him",
   donation: "donation",
@@ -95,7 +150,10 @@ const zh: typeof en = {
     username: '用户名',
     password: '密码',
     sign_out: '登出',
-    sign_in: '登录'
+    sign_in: '登录',
+    cancel: '取消',
+    or: '或',
+    sign_in_sso: '使用单点登录'
   },
   dashboard: {
     command_example: '命令示例',
@@ -108,6 +166,8 @@ const zh: typeof en = {
     input_server_id: '在此输入服务器名称',
     input_server_address: '在此输入服务器地址',
     view_authorized_keys: '查看公钥',
+    require_mfa: '要求两步验证',
+    intro_require_mfa: '只有通过两步验证登录的用户才能连接',
     intro_authorized_keys: '为了让服务器的 SSH 连接可以被 Bunker 中继，请将以下公钥添加到目标服务器用户的 <code>$HOME/.ssh/authorized_keys</code> 文件中',
   },
   users: {
@@ -124,6 +184,11 @@ const zh: typeof en = {
     title: '授权管理',
     add_grant: '添加授权',
     intro_asterisk: '星号 (*) 是通配符，匹配任意服务器用户或服务器名称',
+    duration_hours: '有效时长（小时）',
+    intro_duration: '留空则使用默认时长，未配置时为 8 小时',
+    permanent: '永久有效',
+    expires_at: '过期时间',
+    never: '永不过期',
   },
   ssh_keys: {
     title: 'SSH 公钥',
@@ -143,6 +208,51 @@ const zh: typeof en = {
     repeat_password: '重复密码',
     input_repeat_password: '在此输入重复密码',
   },
+  mfa: {
+    title: '两步验证',
+    enabled: '已启用',
+    not_enabled: '未启用',
+    enroll: '设置',
+    intro_enroll: '使用身份验证器应用生成的一次性密码 (TOTP) 保护你的账户',
+    intro_secret: '将下方的密钥或 URI 添加到身份验证器应用，然后输入其显示的验证码',
+    secret: '密钥',
+    uri: 'URI',
+    code: '验证码',
+    input_code: '在此输入验证码或恢复码',
+    input_totp_code: '在此输入身份验证器应用中的验证码',
+    enable: '验证并启用',
+    disable: '停用',
+    confirm_disable: '确定要停用两步验证吗？',
+    regenerate_recovery_codes: '重新生成恢复码',
+    intro_recovery_codes: '请妥善保存以下恢复码，每个恢复码可代替验证码使用一次，之后不会再次显示',
+    intro_challenge: '请输入身份验证器应用中的验证码，或一个恢复码',
+    verify: '验证',
+  },
+  terminal: {
+    title: '终端',
+    open: '终端',
+    intro: '输入服务器用户和服务器名称，连接后即可在浏览器中打开终端',
+    connect: '连接',
+    disconnect: '断开',
+    closed: '连接已关闭',
+  },
+  sessions: {
+    title: '实时会话',
+    intro: '当前通过 Bunker 连接的会话，管理员可以旁观会话、加入会话进行输入，或终止会话',
+    refresh: '刷新',
+    target: '目标',
+    remote_addr: '远程地址',
+    started_at: '开始时间',
+    shadow: '旁观',
+    terminate: '终止',
+    confirm_terminate: '确定要终止该会话吗？',
+    read_only: '只读',
+    joined: '已加入',
+    closed: '会话已结束',
+    join: '加入',
+    leave: '返回只读',
+    confirm_join: '你的输入将被发送到该会话，且用户会收到通知，是否继续？',
+  },
   lastwill: "存活?",
   pronouns: "他",
   location: "深圳，中国",
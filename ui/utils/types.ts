@@ -1,6 +1,7 @@
 export interface BServer {
   id: string;
   address: string;
+  require_mfa: boolean;
 }
 
 export interface BKey {
@@ -16,6 +17,8 @@ export interface BUser {
   visited_at: string;
   is_admin: boolean;
   is_blocked: boolean;
+  source: string;
+  totp_enabled: boolean;
 }
 
 export interface BToken {
@@ -32,8 +35,20 @@ export interface BGrant {
   server_user: string;
   server_id: string;
   created_at: string;
+  expires_at?: string;
 }
 export interface BGrantedItem {
   server_user: string;
   server_id: string;
-}
\ No newline at end of file
+}
+export interface BLiveSession {
+  id: string;
+  user_id: string;
+  server_id: string;
+  server_user: string;
+  remote_addr: string;
+  started_at: string;
+  channels: number;
+  bytes_in: number;
+  bytes_out: number;
+}
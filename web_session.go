@@ -0,0 +1,107 @@
+package bunker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+)
+
+const (
+	// VisitedAt of tokens is updated at most once per interval
+	tokenVisitInterval = time.Minute
+	tokenSweepInterval = time.Minute * 10
+)
+
+// requestIP returns the ip address of the remote peer
+func requestIP(req *http.Request) string {
+	return addrIP(req.RemoteAddr)
+}
+
+// webSessionID returns the public identifier of a token, token id is a secret
+func webSessionID(tokenID string) string {
+	sum := sha256.Sum256([]byte(tokenID))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (a *App) tokenLifetime() time.Duration {
+	return time.Duration(a.authOpts.TokenLifetime) * time.Second
+}
+
+func (a *App) tokenIdleTimeout() time.Duration {
+	return time.Duration(a.authOpts.TokenIdleTimeout) * time.Second
+}
+
+// isTokenExpired checks the absolute and idle lifetime of token
+func (a *App) isTokenExpired(token *model.Token, now time.Time) bool {
+	if token.CreatedAt.Add(a.tokenLifetime()).Before(now) {
+		return true
+	}
+	if a.authOpts.TokenIdleTimeout > 0 && token.VisitedAt.Add(a.tokenIdleTimeout()).Before(now) {
+		return true
+	}
+	return false
+}
+
+// visitToken slides VisitedAt of token and user
+func (a *App) visitToken(token *model.Token, ip string, now time.Time) (err error) {
+	if now.Sub(token.VisitedAt) < tokenVisitInterval && token.IP == ip {
+		return
+	}
+
+	db := dao.Use(a.db)
+
+	if _, err = db.Token.Where(db.Token.ID.Eq(token.ID)).UpdateColumnSimple(
+		db.Token.VisitedAt.Value(now),
+		db.Token.IP.Value(ip),
+	); err != nil {
+		return
+	}
+	if _, err = db.User.Where(db.User.ID.Eq(token.UserID)).UpdateColumnSimple(db.User.VisitedAt.Value(now)); err != nil {
+		return
+	}
+
+	token.VisitedAt, token.IP = now, ip
+	return
+}
+
+// sweepTokens deletes expired tokens and sign-in challenges
+func (a *App) sweepTokens() (err error) {
+	db := dao.Use(a.db)
+
+	now := time.Now()
+
+	q := db.Token.Where(db.Token.CreatedAt.Lt(now.Add(-a.tokenLifetime())))
+	if a.authOpts.TokenIdleTimeout > 0 {
+		q = q.Or(db.Token.VisitedAt.Lt(now.Add(-a.tokenIdleTimeout())))
+	}
+	if _, err = q.Delete(); err != nil {
+		return
+	}
+
+	if _, err = db.MFAChallenge.Where(db.MFAChallenge.CreatedAt.Lt(now.Add(-mfaChallengeTTL))).Delete(); err != nil {
+		return
+	}
+	return
+}
+
+// runTokenSweeper sweeps expired tokens periodically until ctx is done
+func (a *App) runTokenSweeper(ctx context.Context) {
+	ticker := time.NewTicker(tokenSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.sweepTokens(); err != nil {
+				a.log.With("error", err).Error("token sweeper")
+			}
+		}
+	}
+}
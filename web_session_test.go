@@ -0,0 +1,44 @@
+package bunker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yankeguo/bunker/model"
+)
+
+func TestIsTokenExpired(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		lifetime    int
+		idleTimeout int
+		createdAgo  time.Duration
+		visitedAgo  time.Duration
+		expired     bool
+	}{
+		{"fresh", 3600, 600, time.Minute, time.Minute, false},
+		{"within lifetime and idle timeout", 3600, 600, time.Minute * 50, time.Minute * 9, false},
+		{"lifetime exceeded", 3600, 600, time.Minute * 61, time.Second, true},
+		{"lifetime exceeded with idle timeout disabled", 3600, 0, time.Minute * 61, time.Second, true},
+		{"idle timeout exceeded", 3600, 600, time.Minute * 30, time.Minute * 11, true},
+		{"idle timeout disabled", 3600, 0, time.Minute * 30, time.Minute * 29, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{authOpts: authOptions{
+				TokenLifetime:    tt.lifetime,
+				TokenIdleTimeout: tt.idleTimeout,
+			}}
+			token := &model.Token{
+				CreatedAt: now.Add(-tt.createdAgo),
+				VisitedAt: now.Add(-tt.visitedAgo),
+			}
+			if expired := a.isTokenExpired(token, now); expired != tt.expired {
+				t.Fatalf("expected expired %v, got %v", tt.expired, expired)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package bunker
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/git-lfs/wildmatch"
+	"github.com/yankeguo/bunker/model"
+	"github.com/yankeguo/bunker/model/dao"
+	"github.com/yankeguo/ufx"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+)
+
+const (
+	webhookPollInterval = time.Second * 5
+	webhookBatchSize    = 50
+)
+
+type webhookOptions struct {
+	// timeout of each delivery in seconds
+	Timeout int `json:"timeout" default:"10" validate:"min=1"`
+	// deliveries are marked failed after this many attempts
+	MaxAttempts int `json:"max_attempts" default:"10" validate:"min=1"`
+	// delay before the first retry in seconds, doubled on each attempt
+	RetryBackoff int `json:"retry_backoff" default:"30" validate:"min=1"`
+	// maximum delay between retries in seconds
+	MaxRetryBackoff int `json:"max_retry_backoff" default:"3600" validate:"min=1"`
+}
+
+// WebhookDispatcher delivers audit events to webhooks, deliveries are queued in database and retried with backoff
+type WebhookDispatcher struct {
+	opts   webhookOptions
+	db     *gorm.DB
+	client *http.Client
+	log    *zap.SugaredLogger
+	notify chan struct{}
+}
+
+type WebhookDispatcherOptions struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Conf      ufx.Conf
+	DB        *gorm.DB
+	Logger    *zap.SugaredLogger
+}
+
+func CreateWebhookDispatcher(opts WebhookDispatcherOptions) (wd *WebhookDispatcher, err error) {
+	wd = &WebhookDispatcher{
+		db:     opts.DB,
+		log:    opts.Logger,
+		notify: make(chan struct{}, 1),
+	}
+
+	if err = opts.Conf.Bind(&wd.opts, "webhook"); err != nil {
+		return
+	}
+
+	wd.client = &http.Client{Timeout: time.Duration(wd.opts.Timeout) * time.Second}
+
+	if opts.Lifecycle != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		opts.Lifecycle.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				go wd.Run(ctx)
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				cancel()
+				return nil
+			},
+		})
+	}
+	return
+}
+
+// matchWebhookEvents checks if the event matches the comma separated patterns of webhook
+func matchWebhookEvents(events string, event *model.AuditEvent) bool {
+	for _, pattern := range strings.Split(events, ",") {
+		action, result, _ := strings.Cut(strings.TrimSpace(pattern), ":")
+		if action == "" {
+			continue
+		}
+		if result != "" && result != event.Result {
+			continue
+		}
+		if wildmatch.NewWildmatch(action, wildmatch.CaseFold).Match(event.Action) {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookSignature returns the HMAC-SHA256 signature of payload
+func webhookSignature(secret string, payload string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Enqueue queues deliveries of event to matching webhooks, should be called in the transaction recording the event
+func (wd *WebhookDispatcher) Enqueue(tx *dao.Query, event *model.AuditEvent) (err error) {
+	var hooks []*model.Webhook
+	if hooks, err = tx.Webhook.Where(tx.Webhook.Enabled.Is(true)).Find(); err != nil {
+		return
+	}
+
+	var payload []byte
+
+	for _, hook := range hooks {
+		if !matchWebhookEvents(hook.Events, event) {
+			continue
+		}
+
+		if payload == nil {
+			if payload, err = json.Marshal(event); err != nil {
+				return
+			}
+		}
+
+		if err = tx.WebhookDelivery.Create(&model.WebhookDelivery{
+			WebhookID:     hook.ID,
+			AuditEventID:  event.ID,
+			Action:        event.Action,
+			Payload:       string(payload),
+			Status:        model.WebhookDeliveryPending,
+			NextAttemptAt: event.CreatedAt,
+			CreatedAt:     event.CreatedAt,
+		}); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Notify wakes up the dispatcher to deliver queued events
+func (wd *WebhookDispatcher) Notify() {
+	select {
+	case wd.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers queued events until ctx is done
+func (wd *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wd.notify:
+		}
+
+		if err := wd.Dispatch(ctx); err != nil {
+			wd.log.With("error", err).Error("webhook dispatch")
+		}
+	}
+}
+
+// Dispatch delivers due deliveries
+func (wd *WebhookDispatcher) Dispatch(ctx context.Context) (err error) {
+	db := dao.Use(wd.db)
+
+	for {
+		var deliveries []*model.WebhookDelivery
+		if deliveries, err = db.WebhookDelivery.Where(
+			db.WebhookDelivery.Status.Eq(model.WebhookDeliveryPending),
+			db.WebhookDelivery.NextAttemptAt.Lte(time.Now()),
+		).Order(db.WebhookDelivery.NextAttemptAt, db.WebhookDelivery.ID).Limit(webhookBatchSize).Find(); err != nil {
+			return
+		}
+
+		if len(deliveries) == 0 {
+			return
+		}
+
+		hooks := map[string]*model.Webhook{}
+
+		for _, delivery := range deliveries {
+			if ctx.Err() != nil {
+				return
+			}
+
+			hook, ok := hooks[delivery.WebhookID]
+			if !ok {
+				if hook, err = db.Webhook.Where(db.Webhook.ID.Eq(delivery.WebhookID)).First(); err != nil {
+					if !errors.Is(err, gorm.ErrRecordNotFound) {
+						return
+					}
+					hook, err = nil, nil
+				}
+				hooks[delivery.WebhookID] = hook
+			}
+
+			if err = wd.deliver(ctx, hook, delivery); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (wd *WebhookDispatcher) backoff(attempts int) time.Duration {
+	backoff := time.Duration(wd.opts.RetryBackoff) * time.Second
+	limit := time.Duration(wd.opts.MaxRetryBackoff) * time.Second
+
+	for i := 1; i < attempts && backoff < limit; i++ {
+		backoff *= 2
+	}
+	return min(backoff, limit)
+}
+
+// deliver attempts the delivery once and updates its status
+func (wd *WebhookDispatcher) deliver(ctx context.Context, hook *model.Webhook, delivery *model.WebhookDelivery) (err error) {
+	db := dao.Use(wd.db)
+
+	var (
+		code    int
+		sendErr error
+		now     = time.Now()
+	)
+
+	if hook == nil {
+		sendErr = errors.New("webhook deleted")
+	} else if !hook.Enabled {
+		sendErr = errors.New("webhook disabled")
+	} else {
+		code, sendErr = wd.send(ctx, hook, delivery)
+	}
+
+	delivery.Attempts++
+	delivery.ResponseCode = code
+	delivery.LastError = ""
+
+	if sendErr == nil {
+		delivery.Status = model.WebhookDeliverySuccess
+		delivery.DeliveredAt = &now
+	} else {
+		delivery.LastError = sendErr.Error()
+
+		if hook == nil || !hook.Enabled || delivery.Attempts >= wd.opts.MaxAttempts {
+			delivery.Status = model.WebhookDeliveryFailed
+		} else {
+			delivery.NextAttemptAt = now.Add(wd.backoff(delivery.Attempts))
+		}
+
+		wd.log.With(
+			"webhook_id", delivery.WebhookID,
+			"delivery_id", delivery.ID,
+			"attempts", delivery.Attempts,
+			"error", sendErr,
+		).Warn("webhook delivery")
+	}
+
+	assigns := []field.AssignExpr{
+		db.WebhookDelivery.Status.Value(delivery.Status),
+		db.WebhookDelivery.Attempts.Value(delivery.Attempts),
+		db.WebhookDelivery.NextAttemptAt.Value(delivery.NextAttemptAt),
+		db.WebhookDelivery.ResponseCode.Value(delivery.ResponseCode),
+		db.WebhookDelivery.LastError.Value(delivery.LastError),
+	}
+
+	if delivery.DeliveredAt != nil {
+		assigns = append(assigns, db.WebhookDelivery.DeliveredAt.Value(*delivery.DeliveredAt))
+	}
+
+	_, err = db.WebhookDelivery.Where(db.WebhookDelivery.ID.Eq(delivery.ID)).UpdateColumnSimple(assigns...)
+	return
+}
+
+// send posts the payload to webhook, signed with the secret of webhook
+func (wd *WebhookDispatcher) send(ctx context.Context, hook *model.Webhook, delivery *model.WebhookDelivery) (code int, err error) {
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, strings.NewReader(delivery.Payload)); err != nil {
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bunker-webhook")
+	req.Header.Set("X-Bunker-Event", delivery.Action)
+	req.Header.Set("X-Bunker-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Bunker-Signature", webhookSignature(hook.Secret, delivery.Payload))
+
+	var res *http.Response
+	if res, err = wd.client.Do(req); err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+
+	code = res.StatusCode
+
+	if code < 200 || code >= 300 {
+		err = fmt.Errorf("unexpected status %d", code)
+		return
+	}
+	return
+}